@@ -27,6 +27,7 @@ package v1alpha1
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -53,13 +54,282 @@ type ClusterObjectStatus struct {
 	// +listMapKey=type
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// observedGeneration is the generation of the ClusterObject, which was reconciled last
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// summary contains the counters over all replicated objects in the target namespaces
+	// +optional
+	Summary ClusterObjectSummary `json:"summary,omitempty,omitzero"`
+
+	// targets lists the replication state of the replicated objects per target namespace. To stay
+	// inside the size limits of an object, the list is limited. Failed and skipped targets are
+	// listed first, targets which are in sync are omitted first.
+	// +listType=map
+	// +listMapKey=namespace
+	// +listMapKey=apiVersion
+	// +listMapKey=kind
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=250
+	// +optional
+	Targets []ClusterObjectTarget `json:"targets,omitempty"`
+
+	// omittedTargets is the number of targets, which are not listed in targets
+	// +optional
+	OmittedTargets int32 `json:"omittedTargets,omitempty"`
+
+	// inventory lists the objects, which were replicated by the ClusterObject. Objects, which are
+	// removed from the resources of the ClusterObject, are pruned with the help of the inventory.
+	// +listType=atomic
+	// +optional
+	Inventory []ClusterObjectResourceRef `json:"inventory,omitempty"`
+
+	// hierarchy reports the tree of namespaces, which is selected by the hierarchy of the replicator
+	// +optional
+	Hierarchy *ClusterObjectHierarchyStatus `json:"hierarchy,omitempty"`
+
+	// rollout reports the progress of the rollout of the resources, if a rollout is configured
+	// +optional
+	Rollout *ClusterObjectRolloutStatus `json:"rollout,omitempty"`
+}
+
+// ClusterObjectRolloutStatus reports the progress of the rollout of the resources
+type ClusterObjectRolloutStatus struct {
+	// revision is the hash of the resources, which are rolled out
+	// +required
+	Revision string `json:"revision"`
+
+	// phase is the phase of the rollout
+	// +required
+	Phase RolloutPhase `json:"phase"`
+
+	// step is the current step of the rollout, starting with 0. If canaries are configured,
+	// the canaries are the first step.
+	// +optional
+	Step int32 `json:"step"`
+
+	// steps is the number of steps of the rollout
+	// +optional
+	Steps int32 `json:"steps"`
+
+	// updatedNamespaces is the number of target namespaces, which are part of the current
+	// or a finished step
+	// +optional
+	UpdatedNamespaces int32 `json:"updatedNamespaces"`
+
+	// namespaces is the number of target namespaces of the rollout
+	// +optional
+	Namespaces int32 `json:"namespaces"`
+
+	// failures is the number of failed objects of the rollout
+	// +optional
+	Failures int32 `json:"failures,omitempty"`
+
+	// stepStartTime is the time, the current step was started
+	// +optional
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+
+	// message describes, what the rollout is waiting for
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RolloutPhase is the phase of the rollout of the resources
+// +kubebuilder:validation:Enum=Progressing;Halted;Completed
+type RolloutPhase string
+
+const (
+	// RolloutPhaseProgressing means the resources are rolled out step by step
+	RolloutPhaseProgressing RolloutPhase = "Progressing"
+	// RolloutPhaseHalted means too many objects failed, the remaining namespaces are not
+	// updated until the resources change
+	RolloutPhaseHalted RolloutPhase = "Halted"
+	// RolloutPhaseCompleted means the resources were rolled out to all target namespaces
+	RolloutPhaseCompleted RolloutPhase = "Completed"
+)
+
+// ClusterObjectHierarchyStatus reports the tree of namespaces below the root namespace
+type ClusterObjectHierarchyStatus struct {
+	// namespaces is the number of namespaces, which are selected by the hierarchy
+	// +optional
+	Namespaces int32 `json:"namespaces"`
+
+	// depth is the maximum depth of the selected namespaces below the root
+	// +optional
+	Depth int32 `json:"depth"`
+
+	// cycles lists the namespaces, whose parents form a cycle. These namespaces are not
+	// part of the tree.
+	// +kubebuilder:validation:MaxItems=50
+	// +listType=set
+	// +optional
+	Cycles []string `json:"cycles,omitempty"`
+}
+
+// ClusterObjectResourceRef references a replicated object inside the target namespaces
+type ClusterObjectResourceRef struct {
+	// apiVersion is the api version of the replicated object
+	// +required
+	APIVersion string `json:"apiVersion"`
+
+	// kind is the kind of the replicated object
+	// +required
+	Kind string `json:"kind"`
+
+	// name is the name of the replicated object
+	// +required
+	Name string `json:"name"`
+}
+
+// ClusterObjectSummary contains the counters over all replicated objects in the target namespaces
+type ClusterObjectSummary struct {
+	// desired is the number of objects, which should exist in the target namespaces
+	// +optional
+	Desired int32 `json:"desired"`
+
+	// synced is the number of objects, which are in the desired state
+	// +optional
+	Synced int32 `json:"synced"`
+
+	// failed is the number of objects, whose replication failed
+	// +optional
+	Failed int32 `json:"failed"`
+
+	// skipped is the number of objects, which were skipped because of a conflict
+	// +optional
+	Skipped int32 `json:"skipped"`
+
+	// quarantined is the number of objects, which failed too often and are retried less frequently
+	// +optional
+	Quarantined int32 `json:"quarantined"`
+
+	// planned is the number of objects with a planned action in dry-run mode
+	// +optional
+	Planned int32 `json:"planned,omitempty"`
+
+	// pending is the number of objects, which wait for their namespace to become ready
+	// +optional
+	Pending int32 `json:"pending,omitempty"`
+
+	// waiting is the number of objects, which wait for their step of the rollout
+	// +optional
+	Waiting int32 `json:"waiting,omitempty"`
+}
+
+// TargetState is the replication state of a target namespace
+// +kubebuilder:validation:Enum=Created;Updated;InSync;SkippedConflict;Failed;Quarantined;Deleted;Orphaned;Planned;Pending;Waiting
+type TargetState string
+
+const (
+	// TargetStateCreated means the object was created in the namespace
+	TargetStateCreated TargetState = "Created"
+	// TargetStateUpdated means the object was changed in the namespace
+	TargetStateUpdated TargetState = "Updated"
+	// TargetStateInSync means the object in the namespace already matched the desired state
+	TargetStateInSync TargetState = "InSync"
+	// TargetStateSkippedConflict means the namespace was skipped, because of a conflicting object
+	TargetStateSkippedConflict TargetState = "SkippedConflict"
+	// TargetStateFailed means the replication into the namespace failed
+	TargetStateFailed TargetState = "Failed"
+	// TargetStateQuarantined means the replication into the namespace failed too often,
+	// the namespace is retried less frequently, until the ClusterObject changes
+	TargetStateQuarantined TargetState = "Quarantined"
+	// TargetStateDeleted means the object was removed from the namespace
+	TargetStateDeleted TargetState = "Deleted"
+	// TargetStateOrphaned means the object was left in the namespace without the owner reference
+	TargetStateOrphaned TargetState = "Orphaned"
+	// TargetStatePlanned means an action is planned for the namespace in dry-run mode, the
+	// action was validated with a server-side dry-run
+	TargetStatePlanned TargetState = "Planned"
+	// TargetStatePending means the object is not created yet, because the namespace
+	// is not ready according to the namespaceReadiness of the replicator
+	TargetStatePending TargetState = "Pending"
+	// TargetStateWaiting means the namespace is not updated yet, because it waits for its
+	// step of the rollout
+	TargetStateWaiting TargetState = "Waiting"
+)
+
+// TargetAction is the action, which is planned for a target namespace in dry-run mode
+// +kubebuilder:validation:Enum=Create;Update;Delete
+type TargetAction string
+
+const (
+	// TargetActionCreate means the object would be created in the namespace
+	TargetActionCreate TargetAction = "Create"
+	// TargetActionUpdate means the object would be changed in the namespace
+	TargetActionUpdate TargetAction = "Update"
+	// TargetActionDelete means the object would be removed from the namespace
+	TargetActionDelete TargetAction = "Delete"
+)
+
+// ClusterObjectTarget contains the replication state of a single replicated object in a target namespace
+type ClusterObjectTarget struct {
+	// namespace is the name of the target namespace
+	// +required
+	Namespace string `json:"namespace"`
+
+	// apiVersion is the api version of the replicated object
+	// +required
+	APIVersion string `json:"apiVersion"`
+
+	// kind is the kind of the replicated object
+	// +required
+	Kind string `json:"kind"`
+
+	// name is the name of the replicated object
+	// +required
+	Name string `json:"name"`
+
+	// state is the replication state of the namespace
+	// +required
+	State TargetState `json:"state"`
+
+	// plannedAction is the action, which is planned for the namespace in dry-run mode
+	// +optional
+	PlannedAction TargetAction `json:"plannedAction,omitempty"`
+
+	// resourceVersion is the observed resourceVersion of the replicated object
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// lastSyncTime is the time, the replicated object was last written or deleted
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// lastError is the error of the last failed replication
+	// +kubebuilder:validation:MaxLength=512
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// failures is the number of consecutive failed replications into the namespace
+	// +optional
+	Failures int32 `json:"failures,omitempty"`
+
+	// nextRetryTime is the earliest time, a failed namespace is retried
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+
+	// lastRotationTime is the time, the generated values of the object were generated last
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+
+	// nextRotationTime is the time, the generated values of the object are rotated next
+	// +optional
+	NextRotationTime *metav1.Time `json:"nextRotationTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.summary.desired`
+// +kubebuilder:printcolumn:name="Synced",type=integer,JSONPath=`.status.summary.synced`
+// +kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.summary.failed`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ClusterObject is the Schema for the clusterobjects API
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 63",message="the name of a clusterobject must not be longer than 63 characters"
 type ClusterObject struct {
 	metav1.TypeMeta `json:",inline"`
 
@@ -76,16 +346,510 @@ type ClusterObject struct {
 }
 
 // ClusterObject is the Schema for the clusterobjects API
+// +kubebuilder:validation:XValidation:rule="has(self.resource) || (has(self.resources) && size(self.resources) > 0) || has(self.source)",message="one of resource, resources or source must be set"
+// +kubebuilder:validation:XValidation:rule="!(has(self.targetAll) && self.targetAll) || !(has(self.labelSelector) || has(self.namespaces) || has(self.namespaceCEL) || has(self.hierarchy))",message="targetAll can not be combined with labelSelector, namespaces, namespaceCEL or hierarchy"
 type ClusterObjectReplicator struct {
 
+	// targetAll selects all namespaces. The exclusions and the system namespaces of the
+	// operator still apply. It can not be combined with the other selections.
+	// +optional
+	TargetAll bool `json:"targetAll,omitempty"`
+
+	// labelSelector selects the target namespaces by their labels. If neither targetAll nor
+	// any other selection is set, the replicator selects no namespace.
 	// +optional
 	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty" protobuf:"bytes,4,opt,name=labelSelector"`
 
+	// namespaces selects the target namespaces by their names. It is combined with the
+	// labelSelector according to its operator.
+	// +optional
+	Namespaces *ClusterObjectNamespaces `json:"namespaces,omitempty"`
+
+	// namespaceCEL is a CEL expression, which selects the target namespaces. The expression is
+	// evaluated against the full Namespace object in the variable object and must return a bool,
+	// e.g. object.metadata.annotations[?'team'].orValue('') in ['a', 'b'].
+	// The labels and annotations of the namespace are always set, even if they are empty.
+	// It is combined with the labelSelector and the namespaces, a namespace must match all of them.
+	// If neither is set, only the expression is evaluated.
+	// +optional
+	NamespaceCEL string `json:"namespaceCEL,omitempty"`
+
+	// hierarchy selects a root namespace and all of its descendants. The tree of the namespaces is
+	// resolved through the parent label or annotation of the namespaces. It is combined with the
+	// other selections, a namespace must match all of them.
+	// +optional
+	Hierarchy *ClusterObjectHierarchy `json:"hierarchy,omitempty"`
+
+	// requireObjects are objects, which must exist in a namespace, before it is a target, e.g.
+	// a ServiceAccount or a Deployment with a specific label. A namespace must contain a matching
+	// object for every entry. The list is combined with the other selections.
+	// +optional
+	RequireObjects []ClusterObjectRequiredObject `json:"requireObjects,omitempty"`
+
+	// namespaceReadiness delays the replication into new namespaces, until they are ready.
+	// Objects, which already exist in a namespace, are updated regardless of the readiness.
+	// +optional
+	NamespaceReadiness *ClusterObjectNamespaceReadiness `json:"namespaceReadiness,omitempty"`
+
+	// excludeSelector excludes the namespaces, whose labels match the selector, even if
+	// they are selected by the labelSelector or the namespaces.
+	// +optional
+	ExcludeSelector *metav1.LabelSelector `json:"excludeSelector,omitempty"`
+
+	// excludeNamespaces excludes namespaces by their names, even if they are selected by the
+	// labelSelector or the namespaces. Shell globs like kube-* are supported.
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	// resource is a single object, which is replicated into the target namespaces
 	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Resource unstructured.Unstructured `json:"resource,omitempty,omitzero"`
+
+	// resources is a list of objects, which are replicated together into the target namespaces.
+	// The objects are applied in the order of their sync wave, which is set with the annotation
+	// "cluster.jnnkrdb.de/sync-wave", and the kind of the object. Objects with the same wave and
+	// kind keep the order of the list. The objects are deleted in the reverse order. Objects, which
+	// are removed from the list, are pruned from the target namespaces.
+	// +kubebuilder:validation:items:XPreserveUnknownFields
+	// +kubebuilder:validation:items:Type=object
+	// +optional
+	Resources []unstructured.Unstructured `json:"resources,omitempty"`
+
+	// source references an existing object, which is replicated into the target namespaces. The
+	// server managed metadata of the source is removed, before it is replicated. Changes of the
+	// source are replicated immediately.
+	// +optional
+	Source *ClusterObjectSource `json:"source,omitempty"`
+
+	// generators fill keys of replicated Secrets with random values. The values are generated once
+	// per namespace, when the Secret or the key does not exist, and are kept afterwards, unless a
+	// rotation is configured.
+	// +optional
+	Generators []ClusterObjectGenerator `json:"generators,omitempty"`
+
+	// commonLabels are added to every replicated object. The labels of the resources take precedence.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+
+	// commonAnnotations are added to every replicated object. The annotations of the resources
+	// take precedence.
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+
+	// ignoreDifferences lists fields of the replicated objects, which are changed by other actors in
+	// the target namespaces, e.g. a caBundle injected by a webhook. The values of these fields are
+	// taken from the existing objects and are never overwritten.
+	// +optional
+	IgnoreDifferences []ClusterObjectIgnoreDifference `json:"ignoreDifferences,omitempty"`
+
+	// template enables the rendering of the resources as go templates for every target namespace.
+	// All string values and keys of the resources are rendered, except apiVersion, kind and
+	// metadata.name. The target namespace is available as .Namespace with the fields .Name,
+	// .Labels and .Annotations, the hermetic functions of sprig are available as well.
+	// +optional
+	Template bool `json:"template,omitempty"`
+
+	// overlays are patches, which are applied to the resources for a group of target namespaces.
+	// The overlays are applied in order on top of the resources, before the resources are rendered
+	// and written to the namespaces.
+	// +optional
+	Overlays []ClusterObjectOverlay `json:"overlays,omitempty"`
+
+	// dataMergeStrategy defines how the keys of replicated ConfigMaps and Secrets are handled, which
+	// are not declared by the clusterobject. Replace removes these keys, Merge keeps them. Keys, which
+	// are removed from the clusterobject, are pruned with both strategies. Defaults to Replace.
+	// +kubebuilder:default=Replace
+	// +optional
+	DataMergeStrategy DataMergeStrategy `json:"dataMergeStrategy,omitempty"`
+
+	// conflictPolicy defines how already existing objects in a target namespace are handled,
+	// which are not controlled by this clusterobject. Defaults to Skip.
+	// +kubebuilder:default=Skip
+	// +optional
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+
+	// deletionPolicy defines what happens with the replicated objects, when the clusterobject
+	// is deleted. Defaults to Delete.
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// dryRun enables the dry-run mode. The replicator calculates the planned action for every
+	// namespace and validates it with a server-side dry-run, but does not change any namespace.
+	// The planned actions are reported in the status.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// rollout rolls changes of the resources out progressively. The canary namespaces are updated
+	// first, the other target namespaces follow in batches. The next step only starts, after the
+	// objects of the current step are healthy and the pause passed. If not set, all target
+	// namespaces are updated at once.
+	// +optional
+	Rollout *ClusterObjectRollout `json:"rollout,omitempty"`
+}
+
+// ClusterObjectRollout defines the progressive rollout of changes of the resources
+type ClusterObjectRollout struct {
+	// canary selects the namespaces, which are updated in the first step
+	// +optional
+	Canary *ClusterObjectRolloutCanary `json:"canary,omitempty"`
+
+	// batchSize is the number of namespaces, which are updated per step after the canaries.
+	// It is either a count, e.g. 5, or a percentage of the remaining target namespaces, e.g.
+	// 25%. Percentages are rounded up. Defaults to 25%.
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:XValidation:rule="type(self) == int ? self > 0 : self.matches('^[1-9][0-9]?%$|^100%$')",message="batchSize must be a positive count or a percentage between 1% and 100%"
+	// +optional
+	BatchSize *intstr.IntOrString `json:"batchSize,omitempty"`
+
+	// pause is the minimum duration between the start of two steps
+	// +optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+
+	// maxFailures is the number of failed objects in the updated namespaces, which is tolerated.
+	// If more objects fail, the rollout halts until the resources change. It is either a count
+	// or a percentage of all objects of the rollout. Defaults to 0.
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:XValidation:rule="type(self) == int ? self >= 0 : self.matches('^[0-9]{1,2}%$|^100%$')",message="maxFailures must be a count or a percentage between 0% and 100%"
+	// +optional
+	MaxFailures *intstr.IntOrString `json:"maxFailures,omitempty"`
+}
+
+// ClusterObjectRolloutCanary selects the canary namespaces of a rollout. A namespace is a
+// canary, if it matches the names or the selector. Canaries, which are no target
+// namespaces, are ignored.
+// +kubebuilder:validation:XValidation:rule="(has(self.names) && size(self.names) > 0) || has(self.selector)",message="at least one of names or selector must be set"
+type ClusterObjectRolloutCanary struct {
+	// names are the names of the canary namespaces
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// selector selects the canary namespaces by their labels
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ClusterObjectSource references an existing object, which is replicated
+type ClusterObjectSource struct {
+	// apiVersion is the api version of the source object
+	// +kubebuilder:validation:MinLength=1
 	// +required
-	Resource unstructured.Unstructured `json:"resource"`
+	APIVersion string `json:"apiVersion"`
+
+	// kind is the kind of the source object
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Kind string `json:"kind"`
+
+	// namespace is the namespace of the source object
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Namespace string `json:"namespace"`
+
+	// name is the name of the source object
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Name string `json:"name"`
+
+	// keys selects the keys of data and binaryData, which are replicated. If not set, all
+	// keys are replicated.
+	// +optional
+	Keys []ClusterObjectSourceKey `json:"keys,omitempty"`
 }
 
+// ClusterObjectSourceKey selects a key of the source object, which is replicated
+type ClusterObjectSourceKey struct {
+	// key is the key in the source object
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Key string `json:"key"`
+
+	// toKey is the key in the replicated object. Defaults to key.
+	// +optional
+	ToKey string `json:"toKey,omitempty"`
+}
+
+// ClusterObjectGenerator generates random values for the keys of a replicated Secret
+type ClusterObjectGenerator struct {
+	// secretName is the name of the Secret in the resources, whose keys are generated
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	SecretName string `json:"secretName"`
+
+	// keys are the generated keys of the Secret
+	// +kubebuilder:validation:MinItems=1
+	// +required
+	Keys []ClusterObjectGeneratorKey `json:"keys"`
+
+	// rotationInterval enables the rotation of the generated values. All keys of the generator
+	// are generated again, after the interval passed. If not set, the values are never rotated.
+	// +optional
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty"`
+}
+
+// ClusterObjectGeneratorKey describes a generated key of a Secret
+type ClusterObjectGeneratorKey struct {
+	// key is the key in the data of the Secret
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Key string `json:"key"`
+
+	// length is the number of generated characters, or the number of generated bytes,
+	// if an encoding is set. Defaults to 32.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4096
+	// +kubebuilder:default=32
+	// +optional
+	Length int32 `json:"length,omitempty"`
+
+	// charset is the set of characters, the value is generated from. It is ignored, if an
+	// encoding is set. Defaults to Alphanumeric.
+	// +kubebuilder:default=Alphanumeric
+	// +optional
+	Charset GeneratorCharset `json:"charset,omitempty"`
+
+	// encoding encodes random bytes instead of generating characters. Defaults to None.
+	// +kubebuilder:default=None
+	// +optional
+	Encoding GeneratorEncoding `json:"encoding,omitempty"`
+}
+
+// GeneratorCharset is the set of characters of a generated value
+// +kubebuilder:validation:Enum=Alphanumeric;Alphabetic;Numeric;Hex;ASCII
+type GeneratorCharset string
+
+const (
+	// GeneratorCharsetAlphanumeric contains the letters a-z, A-Z and the digits 0-9
+	GeneratorCharsetAlphanumeric GeneratorCharset = "Alphanumeric"
+	// GeneratorCharsetAlphabetic contains the letters a-z and A-Z
+	GeneratorCharsetAlphabetic GeneratorCharset = "Alphabetic"
+	// GeneratorCharsetNumeric contains the digits 0-9
+	GeneratorCharsetNumeric GeneratorCharset = "Numeric"
+	// GeneratorCharsetHex contains the digits 0-9 and the letters a-f
+	GeneratorCharsetHex GeneratorCharset = "Hex"
+	// GeneratorCharsetASCII contains all printable ascii characters except the space
+	GeneratorCharsetASCII GeneratorCharset = "ASCII"
+)
+
+// GeneratorEncoding is the encoding of a generated value
+// +kubebuilder:validation:Enum=None;Base64;Hex
+type GeneratorEncoding string
+
+const (
+	// GeneratorEncodingNone generates the value from the characters of the charset
+	GeneratorEncodingNone GeneratorEncoding = "None"
+	// GeneratorEncodingBase64 generates random bytes and encodes them with base64
+	GeneratorEncodingBase64 GeneratorEncoding = "Base64"
+	// GeneratorEncodingHex generates random bytes and encodes them as hex
+	GeneratorEncodingHex GeneratorEncoding = "Hex"
+)
+
+// ClusterObjectNamespaces selects namespaces by their names. A namespace matches, if its
+// name matches any of the names, globs or regular expressions.
+// +kubebuilder:validation:XValidation:rule="(has(self.names) && size(self.names) > 0) || (has(self.globs) && size(self.globs) > 0) || (has(self.regexes) && size(self.regexes) > 0)",message="at least one of names, globs or regexes must be set"
+type ClusterObjectNamespaces struct {
+	// names are the exact names of the namespaces
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// globs are shell patterns of the names, e.g. team-*
+	// +optional
+	Globs []string `json:"globs,omitempty"`
+
+	// regexes are regular expressions (RE2), which must match the whole name, e.g. team-(a|b)-.*
+	// +optional
+	Regexes []string `json:"regexes,omitempty"`
+
+	// operator defines how the names are combined with the labelSelector of the replicator.
+	// With And, a namespace must match both, with Or, it must match one of them. If no
+	// labelSelector is set, only the names are evaluated. Defaults to And.
+	// +kubebuilder:default=And
+	// +optional
+	Operator SelectorOperator `json:"operator,omitempty"`
+}
+
+// ClusterObjectHierarchy selects a tree of namespaces below a root namespace
+// +kubebuilder:validation:XValidation:rule="!(has(self.parentLabel) && has(self.parentAnnotation))",message="only one of parentLabel or parentAnnotation can be set"
+type ClusterObjectHierarchy struct {
+	// root is the name of the root namespace of the tree
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Root string `json:"root"`
+
+	// parentLabel is the key of the label, which contains the name of the parent namespace.
+	// Defaults to cluster.jnnkrdb.de/parent, if parentAnnotation is not set.
+	// +optional
+	ParentLabel string `json:"parentLabel,omitempty"`
+
+	// parentAnnotation is the key of the annotation, which contains the name of the parent namespace
+	// +optional
+	ParentAnnotation string `json:"parentAnnotation,omitempty"`
+
+	// maxDepth is the maximum depth of the selected descendants below the root. The children
+	// of the root have the depth 1. If not set, all descendants are selected.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDepth *int32 `json:"maxDepth,omitempty"`
+
+	// excludeRoot excludes the root namespace itself, only its descendants are selected
+	// +optional
+	ExcludeRoot bool `json:"excludeRoot,omitempty"`
+}
+
+// ClusterObjectRequiredObject selects objects, which must exist in a target namespace
+type ClusterObjectRequiredObject struct {
+	// apiVersion is the api version of the required objects
+	// +required
+	APIVersion string `json:"apiVersion"`
+
+	// kind is the kind of the required objects
+	// +required
+	Kind string `json:"kind"`
+
+	// name is the name of the required object. If not set, any object of the kind matches.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// labelSelector selects the required objects by their labels. If not set, the labels
+	// are not evaluated.
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// ClusterObjectNamespaceReadiness defines, when a namespace is ready for the replication.
+// If both fields are set, a namespace must pass both.
+type ClusterObjectNamespaceReadiness struct {
+	// minAge is the minimum age of a namespace, before objects are created in it
+	// +optional
+	MinAge *metav1.Duration `json:"minAge,omitempty"`
+
+	// label is the key of a label, which must be set on a namespace, before objects
+	// are created in it. The value of the label is not evaluated.
+	// +optional
+	Label string `json:"label,omitempty"`
+}
+
+// ClusterObjectIgnoreDifference selects fields of the replicated objects, which are not overwritten
+// +kubebuilder:validation:XValidation:rule="(has(self.jsonPointers) && size(self.jsonPointers) > 0) || (has(self.jsonPaths) && size(self.jsonPaths) > 0)",message="at least one of jsonPointers or jsonPaths must be set"
+type ClusterObjectIgnoreDifference struct {
+	// kind is the kind of the objects, the fields are ignored for. If not set, the fields
+	// are ignored for all objects.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// name is the name of the objects, the fields are ignored for. If not set, the fields
+	// are ignored for all objects.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// jsonPointers are the ignored fields as JSON pointers (RFC 6901), e.g. /spec/replicas
+	// +optional
+	JSONPointers []string `json:"jsonPointers,omitempty"`
+
+	// jsonPaths are the ignored fields as JSONPath expressions, e.g. .webhooks[*].clientConfig.caBundle.
+	// Fields, array indexes, the wildcard [*] and quoted keys like ['example.com/key'] are supported.
+	// +optional
+	JSONPaths []string `json:"jsonPaths,omitempty"`
+}
+
+// ClusterObjectOverlay is a patch, which is applied to the resources in the selected namespaces
+type ClusterObjectOverlay struct {
+	// namespaceSelector selects the namespaces, in which the overlay is applied. An empty
+	// selector selects all target namespaces.
+	// +required
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// target selects the resources, which are patched. If not set, all resources are patched.
+	// +optional
+	Target *ClusterObjectOverlayTarget `json:"target,omitempty"`
+
+	// type is the type of the patch. Defaults to StrategicMerge.
+	// +kubebuilder:default=StrategicMerge
+	// +optional
+	Type OverlayType `json:"type,omitempty"`
+
+	// patch is the patch in yaml or json format. A strategic merge patch is a partial object,
+	// a JSON 6902 patch is a list of operations.
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Patch string `json:"patch"`
+}
+
+// ClusterObjectOverlayTarget selects the resources, which are patched by an overlay
+type ClusterObjectOverlayTarget struct {
+	// kind is the kind of the patched resources
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// name is the name of the patched resources
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// OverlayType is the type of the patch of an overlay
+// +kubebuilder:validation:Enum=StrategicMerge;JSON6902
+type OverlayType string
+
+const (
+	// OverlayTypeStrategicMerge patches the resource with a strategic merge patch. Kinds, which
+	// are not known to the controller, e.g. custom resources, are patched with a JSON merge patch.
+	OverlayTypeStrategicMerge OverlayType = "StrategicMerge"
+	// OverlayTypeJSON6902 patches the resource with a JSON patch (RFC 6902)
+	OverlayTypeJSON6902 OverlayType = "JSON6902"
+)
+
+// SelectorOperator defines how multiple namespace selections are combined
+// +kubebuilder:validation:Enum=And;Or
+type SelectorOperator string
+
+const (
+	// SelectorOperatorAnd requires a namespace to match all selections
+	SelectorOperatorAnd SelectorOperator = "And"
+	// SelectorOperatorOr requires a namespace to match at least one selection
+	SelectorOperatorOr SelectorOperator = "Or"
+)
+
+// DataMergeStrategy defines the handling of undeclared keys of replicated ConfigMaps and Secrets
+// +kubebuilder:validation:Enum=Replace;Merge
+type DataMergeStrategy string
+
+const (
+	// DataMergeStrategyReplace removes all keys, which are not declared by the ClusterObject
+	DataMergeStrategyReplace DataMergeStrategy = "Replace"
+	// DataMergeStrategyMerge keeps the keys, which were added by others, only the declared keys are owned
+	DataMergeStrategyMerge DataMergeStrategy = "Merge"
+)
+
+// DeletionPolicy defines the handling of the replicated objects, when the ClusterObject is deleted
+// +kubebuilder:validation:Enum=Delete;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes all replicated objects, before the ClusterObject is removed
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps the replicated objects and removes the owner references
+	// and the tracking metadata of the ClusterObject from them
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// ConflictPolicy defines the handling of existing objects, which are not controlled by the ClusterObject
+// +kubebuilder:validation:Enum=Skip;Adopt;Overwrite;Fail
+type ConflictPolicy string
+
+const (
+	// ConflictPolicySkip leaves the existing object untouched and reports the skipped namespace
+	ConflictPolicySkip ConflictPolicy = "Skip"
+	// ConflictPolicyAdopt takes over the controller ownership of the existing object,
+	// if it is not controlled by another owner
+	ConflictPolicyAdopt ConflictPolicy = "Adopt"
+	// ConflictPolicyOverwrite takes over the controller ownership and forces the ownership
+	// of all declared fields, even if they are owned by other field managers
+	ConflictPolicyOverwrite ConflictPolicy = "Overwrite"
+	// ConflictPolicyFail stops the reconciliation of the namespace and reports the conflict
+	ConflictPolicyFail ConflictPolicy = "Fail"
+)
+
 // +kubebuilder:object:root=true
 
 // ClusterObjectList contains a list of ClusterObject
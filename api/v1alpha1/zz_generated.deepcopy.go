@@ -30,7 +30,9 @@ package v1alpha1
 
 import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -60,6 +62,111 @@ func (in *ClusterObject) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectGenerator) DeepCopyInto(out *ClusterObjectGenerator) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]ClusterObjectGeneratorKey, len(*in))
+		copy(*out, *in)
+	}
+	if in.RotationInterval != nil {
+		in, out := &in.RotationInterval, &out.RotationInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectGenerator.
+func (in *ClusterObjectGenerator) DeepCopy() *ClusterObjectGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectGeneratorKey) DeepCopyInto(out *ClusterObjectGeneratorKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectGeneratorKey.
+func (in *ClusterObjectGeneratorKey) DeepCopy() *ClusterObjectGeneratorKey {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectGeneratorKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectHierarchy) DeepCopyInto(out *ClusterObjectHierarchy) {
+	*out = *in
+	if in.MaxDepth != nil {
+		in, out := &in.MaxDepth, &out.MaxDepth
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectHierarchy.
+func (in *ClusterObjectHierarchy) DeepCopy() *ClusterObjectHierarchy {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectHierarchy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectHierarchyStatus) DeepCopyInto(out *ClusterObjectHierarchyStatus) {
+	*out = *in
+	if in.Cycles != nil {
+		in, out := &in.Cycles, &out.Cycles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectHierarchyStatus.
+func (in *ClusterObjectHierarchyStatus) DeepCopy() *ClusterObjectHierarchyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectHierarchyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectIgnoreDifference) DeepCopyInto(out *ClusterObjectIgnoreDifference) {
+	*out = *in
+	if in.JSONPointers != nil {
+		in, out := &in.JSONPointers, &out.JSONPointers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.JSONPaths != nil {
+		in, out := &in.JSONPaths, &out.JSONPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectIgnoreDifference.
+func (in *ClusterObjectIgnoreDifference) DeepCopy() *ClusterObjectIgnoreDifference {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectIgnoreDifference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterObjectList) DeepCopyInto(out *ClusterObjectList) {
 	*out = *in
@@ -92,6 +199,92 @@ func (in *ClusterObjectList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectNamespaceReadiness) DeepCopyInto(out *ClusterObjectNamespaceReadiness) {
+	*out = *in
+	if in.MinAge != nil {
+		in, out := &in.MinAge, &out.MinAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectNamespaceReadiness.
+func (in *ClusterObjectNamespaceReadiness) DeepCopy() *ClusterObjectNamespaceReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectNamespaceReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectNamespaces) DeepCopyInto(out *ClusterObjectNamespaces) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Globs != nil {
+		in, out := &in.Globs, &out.Globs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Regexes != nil {
+		in, out := &in.Regexes, &out.Regexes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectNamespaces.
+func (in *ClusterObjectNamespaces) DeepCopy() *ClusterObjectNamespaces {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectNamespaces)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectOverlay) DeepCopyInto(out *ClusterObjectOverlay) {
+	*out = *in
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(ClusterObjectOverlayTarget)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectOverlay.
+func (in *ClusterObjectOverlay) DeepCopy() *ClusterObjectOverlay {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectOverlay)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectOverlayTarget) DeepCopyInto(out *ClusterObjectOverlayTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectOverlayTarget.
+func (in *ClusterObjectOverlayTarget) DeepCopy() *ClusterObjectOverlayTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectOverlayTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterObjectReplicator) DeepCopyInto(out *ClusterObjectReplicator) {
 	*out = *in
@@ -100,7 +293,91 @@ func (in *ClusterObjectReplicator) DeepCopyInto(out *ClusterObjectReplicator) {
 		*out = new(v1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = new(ClusterObjectNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hierarchy != nil {
+		in, out := &in.Hierarchy, &out.Hierarchy
+		*out = new(ClusterObjectHierarchy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequireObjects != nil {
+		in, out := &in.RequireObjects, &out.RequireObjects
+		*out = make([]ClusterObjectRequiredObject, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NamespaceReadiness != nil {
+		in, out := &in.NamespaceReadiness, &out.NamespaceReadiness
+		*out = new(ClusterObjectNamespaceReadiness)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExcludeSelector != nil {
+		in, out := &in.ExcludeSelector, &out.ExcludeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExcludeNamespaces != nil {
+		in, out := &in.ExcludeNamespaces, &out.ExcludeNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Resource.DeepCopyInto(&out.Resource)
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]unstructured.Unstructured, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(ClusterObjectSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Generators != nil {
+		in, out := &in.Generators, &out.Generators
+		*out = make([]ClusterObjectGenerator, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CommonLabels != nil {
+		in, out := &in.CommonLabels, &out.CommonLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.CommonAnnotations != nil {
+		in, out := &in.CommonAnnotations, &out.CommonAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.IgnoreDifferences != nil {
+		in, out := &in.IgnoreDifferences, &out.IgnoreDifferences
+		*out = make([]ClusterObjectIgnoreDifference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Overlays != nil {
+		in, out := &in.Overlays, &out.Overlays
+		*out = make([]ClusterObjectOverlay, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(ClusterObjectRollout)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectReplicator.
@@ -113,6 +390,155 @@ func (in *ClusterObjectReplicator) DeepCopy() *ClusterObjectReplicator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectRequiredObject) DeepCopyInto(out *ClusterObjectRequiredObject) {
+	*out = *in
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectRequiredObject.
+func (in *ClusterObjectRequiredObject) DeepCopy() *ClusterObjectRequiredObject {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectRequiredObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectResourceRef) DeepCopyInto(out *ClusterObjectResourceRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectResourceRef.
+func (in *ClusterObjectResourceRef) DeepCopy() *ClusterObjectResourceRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectResourceRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectRollout) DeepCopyInto(out *ClusterObjectRollout) {
+	*out = *in
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(ClusterObjectRolloutCanary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BatchSize != nil {
+		in, out := &in.BatchSize, &out.BatchSize
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Pause != nil {
+		in, out := &in.Pause, &out.Pause
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxFailures != nil {
+		in, out := &in.MaxFailures, &out.MaxFailures
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectRollout.
+func (in *ClusterObjectRollout) DeepCopy() *ClusterObjectRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectRolloutCanary) DeepCopyInto(out *ClusterObjectRolloutCanary) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectRolloutCanary.
+func (in *ClusterObjectRolloutCanary) DeepCopy() *ClusterObjectRolloutCanary {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectRolloutCanary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectRolloutStatus) DeepCopyInto(out *ClusterObjectRolloutStatus) {
+	*out = *in
+	if in.StepStartTime != nil {
+		in, out := &in.StepStartTime, &out.StepStartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectRolloutStatus.
+func (in *ClusterObjectRolloutStatus) DeepCopy() *ClusterObjectRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectSource) DeepCopyInto(out *ClusterObjectSource) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]ClusterObjectSourceKey, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectSource.
+func (in *ClusterObjectSource) DeepCopy() *ClusterObjectSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectSourceKey) DeepCopyInto(out *ClusterObjectSourceKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectSourceKey.
+func (in *ClusterObjectSourceKey) DeepCopy() *ClusterObjectSourceKey {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectSourceKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterObjectStatus) DeepCopyInto(out *ClusterObjectStatus) {
 	*out = *in
@@ -123,6 +549,29 @@ func (in *ClusterObjectStatus) DeepCopyInto(out *ClusterObjectStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	out.Summary = in.Summary
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]ClusterObjectTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = make([]ClusterObjectResourceRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Hierarchy != nil {
+		in, out := &in.Hierarchy, &out.Hierarchy
+		*out = new(ClusterObjectHierarchyStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(ClusterObjectRolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectStatus.
@@ -134,3 +583,49 @@ func (in *ClusterObjectStatus) DeepCopy() *ClusterObjectStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectSummary) DeepCopyInto(out *ClusterObjectSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectSummary.
+func (in *ClusterObjectSummary) DeepCopy() *ClusterObjectSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectTarget) DeepCopyInto(out *ClusterObjectTarget) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRetryTime != nil {
+		in, out := &in.NextRetryTime, &out.NextRetryTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRotationTime != nil {
+		in, out := &in.LastRotationTime, &out.LastRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRotationTime != nil {
+		in, out := &in.NextRotationTime, &out.NextRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectTarget.
+func (in *ClusterObjectTarget) DeepCopy() *ClusterObjectTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectTarget)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -20,6 +20,8 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"slices"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -38,6 +40,7 @@ import (
 
 	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
 	"github.com/jnnkrdb/r8r/internal/controller"
+	webhookv1alpha1 "github.com/jnnkrdb/r8r/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -66,6 +69,10 @@ func main() {
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var enableWebhooks bool
+	var maxTargetFailures int
+	var maxConcurrentReconciles, maxConcurrentNamespaces int
+	var systemNamespaces string
 	var tlsOpts []func(*tls.Config)
 	flag.BoolVar(&secureMetrics, "metrics-secure", false, "If set, the metrics endpoint is served securely via HTTPS.")
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook cert.")
@@ -75,6 +82,17 @@ func main() {
 	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics server cert file.")
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false, "If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"If set, the validating webhook for ClusterObjects is served. Requires the webhook certificates.")
+	flag.IntVar(&maxTargetFailures, "max-target-failures", int(controller.DefaultMaxTargetFailures),
+		"The number of consecutive failures, after which a target namespace is quarantined.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The maximum number of ClusterObjects, which are reconciled concurrently.")
+	flag.IntVar(&maxConcurrentNamespaces, "max-concurrent-namespaces", controller.DefaultMaxConcurrentNamespaces,
+		"The maximum number of namespaces, which are reconciled concurrently for a single ClusterObject.")
+	flag.StringVar(&systemNamespaces, "system-namespaces", "kube-system,kube-public",
+		"Comma separated list of namespaces, which are only targets, if a ClusterObject selects them by their names. "+
+			"The namespace of the operator (POD_NAMESPACE) is added automatically.")
 
 	opts := zap.Options{
 		Development: true,
@@ -192,10 +210,21 @@ func main() {
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("clusterobject-controller"),
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		MaxConcurrentNamespaces: maxConcurrentNamespaces,
+		MaxTargetFailures:       int32(maxTargetFailures),
+		SystemNamespaces:        parseSystemNamespaces(systemNamespaces, os.Getenv("POD_NAMESPACE")),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ClusterObject")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err := webhookv1alpha1.SetupClusterObjectWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterObject")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -213,3 +242,16 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// parse the list of system namespaces and add the namespace of the operator
+func parseSystemNamespaces(list, podNamespace string) []string {
+
+	var namespaces []string
+	for _, namespace := range append(strings.Split(list, ","), podNamespace) {
+		if namespace = strings.TrimSpace(namespace); namespace != "" && !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces
+}
@@ -26,28 +26,57 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
 )
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterObjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+
+	// the clusterobjects are indexed by their source, so changes of a source
+	// only trigger the clusterobjects, which reference the source
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&clusterv1alpha1.ClusterObject{},
+		sourceIndexField,
+		indexSource); err != nil {
+		return err
+	}
+
+	// the clusterobjects are indexed by the kinds of their required objects, so
+	// changes of these objects only trigger the affected clusterobjects
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&clusterv1alpha1.ClusterObject{},
+		requiredIndexField,
+		indexRequiredObjects); err != nil {
+		return err
+	}
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&clusterv1alpha1.ClusterObject{}).
 		Named("clusterobject").
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		}).
 		WithEventFilter(
 			predicate.Or(
 				predicate.GenerationChangedPredicate{},
@@ -56,34 +85,57 @@ func (r *ClusterObjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		).
 		Watches(
 			&corev1.Namespace{},
-			handler.EnqueueRequestsFromMapFunc(
-				func(ctx context.Context, obj client.Object) (requests []reconcile.Request) {
-					var _log = log.FromContext(ctx)
-					// trigger reconciliation for all clusterobjects
-					var list = &clusterv1alpha1.ClusterObjectList{}
-					if err := mgr.GetClient().List(ctx, list, &client.ListOptions{}); err != nil {
-						_log.Error(err, "error receiving list of clusterobjects, cannot invoke reconciliation")
-						return
-					}
-					for _, clusterObject := range list.Items {
-						requests = append(requests, reconcile.Request{
-							NamespacedName: types.NamespacedName{
-								Name: clusterObject.Name,
-							},
-						})
-					}
-					return
-				},
-			),
+			r.namespaceEventHandler(),
+			// only changes of the labels can change the selected namespaces, changes
+			// of the annotations can change the rendered templates
+			builder.WithPredicates(predicate.Or(
+				predicate.LabelChangedPredicate{},
+				predicate.AnnotationChangedPredicate{},
+			)),
 		).
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// the kinds of the replicated objects are watched dynamically, as soon
+	// as they appear in a clusterobject
+	r.watches = &dynamicWatches{
+		controller: c,
+		cache:      mgr.GetCache(),
+		watched:    map[string]struct{}{},
+	}
+
+	return nil
 }
 
+// DefaultMaxConcurrentNamespaces is the default number of namespaces, which are
+// reconciled concurrently for a single clusterobject
+const DefaultMaxConcurrentNamespaces = 10
+
 // ClusterObjectReconciler reconciles a ClusterObject object
 type ClusterObjectReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+
+	// MaxConcurrentReconciles is the maximum number of clusterobjects, which are
+	// reconciled concurrently. Defaults to 1.
+	MaxConcurrentReconciles int
+
+	// MaxConcurrentNamespaces is the maximum number of namespaces, which are reconciled
+	// concurrently for a single clusterobject. Defaults to DefaultMaxConcurrentNamespaces.
+	MaxConcurrentNamespaces int
+
+	// MaxTargetFailures is the number of consecutive failures, after which a target
+	// namespace is quarantined. Defaults to DefaultMaxTargetFailures.
+	MaxTargetFailures int32
+
+	// SystemNamespaces are the namespaces, which are never targets, unless they are
+	// selected by their names, e.g. kube-system or the namespace of the operator.
+	SystemNamespaces []string
+
+	watches *dynamicWatches
 }
 
 // +kubebuilder:rbac:groups=cluster.jnnkrdb.de,resources=clusterobjects,verbs=get;list;watch;create;update;patch;delete
@@ -116,6 +168,88 @@ func (r *ClusterObjectReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	_log.V(5).Info("clusterobject content", "*clusterObject", *clusterObject)
 
+	// the clusterobject is being deleted, the replicated objects are handled
+	// according to the deletion policy, before the finalizer is released
+	if !clusterObject.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, clusterObject)
+	}
+
+	// the finalizer is required, to handle the replicated objects on deletion
+	if controllerutil.AddFinalizer(clusterObject, Finalizer) {
+		if err := r.Update(ctx, clusterObject, &client.UpdateOptions{}); err != nil {
+			return ctrl.Result{}, r.throwOnError(
+				ctx,
+				clusterObject,
+				err,
+				"FinalizerConfiguration",
+				"error adding the finalizer to the clusterobject")
+		}
+	}
+
+	// watch the source object, so changes of the source are replicated immediately
+	if source := clusterObject.Replicator.Source; source != nil {
+		if err := r.ensureSourceWatch(ctx, schema.FromAPIVersionAndKind(source.APIVersion, source.Kind)); err != nil {
+			return ctrl.Result{}, r.throwOnError(
+				ctx,
+				clusterObject,
+				err,
+				"SourceWatch",
+				"error watching the kind of the source object")
+		}
+	}
+
+	// watch the kinds of the required objects, so the targets are recalculated, as
+	// soon as required objects appear or disappear
+	for _, required := range clusterObject.Replicator.RequireObjects {
+		if err := r.ensureRequiredWatch(ctx, schema.FromAPIVersionAndKind(required.APIVersion, required.Kind)); err != nil {
+			return ctrl.Result{}, r.throwOnError(
+				ctx,
+				clusterObject,
+				err,
+				"RequiredObjectWatch",
+				"error watching the kind of the required objects")
+		}
+	}
+
+	// calculate the objects, which should be replicated, in the order they are applied
+	resources, err := r.desiredResources(ctx, clusterObject)
+	if err != nil {
+		return ctrl.Result{}, r.throwOnError(
+			ctx,
+			clusterObject,
+			err,
+			"ResourceGathering",
+			"error gathering the resources of the clusterobject")
+	}
+
+	// objects, which were removed from the resources, are pruned
+	var stale = staleResources(clusterObject, resources)
+
+	// watch the kinds of the replicated objects, so changes to the replicated
+	// objects trigger a reconciliation of the clusterobject
+	for _, resource := range resources {
+		if err := r.ensureChildWatch(ctx, resource); err != nil {
+			return ctrl.Result{}, r.throwOnError(
+				ctx,
+				clusterObject,
+				err,
+				"ObjectWatch",
+				"error watching the kind of the replicated object")
+		}
+	}
+
+	// the replicated objects are listed by their tracking label and their controller reference,
+	// so objects, which should not exist, are only fetched from the namespaces, which contain them
+	children, err := r.listChildren(ctx, clusterObject, append(resources[:len(resources):len(resources)], stale...))
+	if err != nil {
+		return ctrl.Result{}, r.throwOnError(
+			ctx,
+			clusterObject,
+			err,
+			"ObjectGathering",
+			"error listing the replicated objects of the clusterobject")
+	}
+
 	// request a list of namespaces, to parse through the list and
 	// then check every namespace with the give item
 	var namespaces = &corev1.NamespaceList{}
@@ -128,44 +262,210 @@ func (r *ClusterObjectReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			"error fetching list of namespaces from cluster")
 	}
 
-	// request a list of namespaces, which are required to inherit the defined object
-	labelselector, err := metav1.LabelSelectorAsSelector(clusterObject.Replicator.LabelSelector)
+	// terminating namespaces are neither targets nor failures
+	namespaces = activeNamespaces(namespaces)
+
+	// calculate the list of namespaces, which are required to inherit the defined object
+	requiredNamespaces, err := r.requiredNamespaces(ctx, clusterObject, namespaces)
 	if err != nil {
 		return ctrl.Result{}, r.throwOnError(
 			ctx,
 			clusterObject,
 			err,
-			"LabelSelectorFetching",
-			"error fetching labelselector from clusterobject")
+			"NamespaceSelection",
+			"error evaluating the namespace selection of the clusterobject")
 	}
-	var requiredNamespaces = &corev1.NamespaceList{}
-	if err := r.List(ctx, requiredNamespaces, &client.ListOptions{LabelSelector: labelselector}); err != nil {
+	_log.V(3).Info("calculated required namespaces", "requiredNamespaces", *requiredNamespaces)
+
+	// the tree of the hierarchy is reported in the status, namespaces in a cycle are no targets
+	clusterObject.Status.Hierarchy = hierarchyStatus(clusterObject, namespaces)
+	if hierarchy := clusterObject.Status.Hierarchy; hierarchy != nil && len(hierarchy.Cycles) > 0 {
+		r.Recorder.Eventf(clusterObject, "Warning", "HierarchyCycle", "%s", describeCycles(hierarchy))
+	}
+
+	// with a rollout, only the namespaces of the current and the finished steps are updated
+	var updated map[string]struct{}
+	var steps [][]string
+	if rollout := clusterObject.Replicator.Rollout; rollout != nil {
+		revision, err := rolloutRevision(clusterObject, resources)
+		if err == nil {
+			steps, err = rolloutSteps(rollout, requiredNamespaces)
+		}
+		if err != nil {
+			return ctrl.Result{}, r.throwOnError(
+				ctx,
+				clusterObject,
+				err,
+				"RolloutPlanning",
+				"error calculating the steps of the rollout")
+		}
+		updated = startRollout(clusterObject, revision, steps)
+	} else {
+		clusterObject.Status.Rollout = nil
+	}
+
+	// parse through all namespaces and check each for the defined objects. a failing
+	// namespace does not block the other namespaces, the errors are collected instead.
+	targets, errs := r.reconcileNamespaces(ctx, clusterObject, namespaces, requiredNamespaces, updated, resources, stale, children)
+
+	// calculate the status of all targets
+	r.setTargetsStatus(clusterObject, desiredTargets(clusterObject, requiredNamespaces, resources), targets)
+
+	// the rollout continues with the next step, as soon as the current step is healthy
+	rolloutAfter, err := r.progressRollout(ctx, clusterObject, steps, resources, targets)
+	if err != nil {
 		return ctrl.Result{}, r.throwOnError(
 			ctx,
 			clusterObject,
 			err,
-			"NamespaceGathering",
-			"error fetching list of namespaces from cluster")
+			"RolloutProgress",
+			"error progressing the rollout")
 	}
-	_log.V(3).Info("calculated required namespaces", "requiredNamespaces", *requiredNamespaces)
 
-	// parse through all namespaces and check each for the defined object
-	for _, namespace := range namespaces.Items {
-		// reconcile the object for a specific namespace, if an error occurs, then throw reconcile error
-		if err := r.reconcileObjectForNamespace(
-			log.IntoContext(ctx, _log.WithValues(
-				"*clusterObject", *clusterObject,
-				"namespace.GetName()", namespace.GetName(),
-			)),
+	// the stale objects are only released from the inventory, if they were pruned from
+	// every namespace
+	setInventory(clusterObject, resources, stale,
+		len(errs) == 0 &&
+			clusterObject.Status.Summary.Failed == 0 &&
+			clusterObject.Status.Summary.Quarantined == 0 &&
+			clusterObject.Status.Summary.Waiting == 0 &&
+			!clusterObject.Replicator.DryRun)
+
+	_log.Info("reconciled", "summary", clusterObject.Status.Summary)
+
+	// rotations, namespaces, which wait for their minimum age, and rollouts require a requeue
+	var requeueAfter = earliestRequeue(
+		earliestRequeue(nextRotationAfter(targets), nextReadinessAfter(clusterObject, requiredNamespaces)),
+		rolloutAfter)
+
+	// a halted rollout does not update the remaining namespaces, until the resources change
+	if rollout := clusterObject.Status.Rollout; rollout != nil && rollout.Phase == clusterv1alpha1.RolloutPhaseHalted &&
+		!clusterObject.Replicator.DryRun {
+		return ctrl.Result{RequeueAfter: earliestRequeue(nextRetryAfter(clusterObject), requeueAfter)}, r.setCondition(
+			ctx,
 			clusterObject,
-			namespace,
-			requiredNamespaces); err != nil {
+			Condition_Ready,
+			metav1.ConditionFalse,
+			"RolloutHalted",
+			"rollout of revision %s %s, %d/%d object(s) synced: %s",
+			rollout.Revision,
+			rollout.Message,
+			clusterObject.Status.Summary.Synced,
+			clusterObject.Status.Summary.Desired,
+			summarizeNames(append(
+				targetObjects(targets, clusterv1alpha1.TargetStateFailed),
+				targetObjects(targets, clusterv1alpha1.TargetStateQuarantined)...)),
+		)
+	}
 
-			return ctrl.Result{}, err
+	// failed namespaces are requeued with their own backoff, the other namespaces are
+	// not affected by the failures
+	if failed := kerrors.NewAggregate(errs); failed != nil ||
+		clusterObject.Status.Summary.Failed > 0 ||
+		clusterObject.Status.Summary.Quarantined > 0 {
+		if failed != nil {
+			_log.Error(failed, "error reconciling namespaces")
 		}
+
+		return ctrl.Result{RequeueAfter: earliestRequeue(nextRetryAfter(clusterObject), requeueAfter)}, r.setCondition(
+			ctx,
+			clusterObject,
+			Condition_Ready,
+			metav1.ConditionFalse,
+			"FailedNamespaces",
+			"deployed resources %s, %d/%d object(s) synced, %d object(s) failed, %d object(s) quarantined: %s",
+			describeResources(resources),
+			clusterObject.Status.Summary.Synced,
+			clusterObject.Status.Summary.Desired,
+			clusterObject.Status.Summary.Failed,
+			clusterObject.Status.Summary.Quarantined,
+			summarizeNames(append(
+				targetObjects(targets, clusterv1alpha1.TargetStateFailed),
+				targetObjects(targets, clusterv1alpha1.TargetStateQuarantined)...)),
+		)
+	}
+
+	// without a selection, the replicator selects no namespace. this is reported, so a
+	// missing selector does not go unnoticed.
+	if !hasNamespaceSelection(clusterObject) {
+		return ctrl.Result{}, r.setCondition(
+			ctx,
+			clusterObject,
+			Condition_Ready,
+			metav1.ConditionFalse,
+			"NoNamespaceSelection",
+			"the replicator selects no namespace, set targetAll, labelSelector, namespaces, namespaceCEL or hierarchy",
+		)
+	}
+
+	// in dry-run mode, the condition reports the planned actions
+	if clusterObject.Replicator.DryRun {
+		return ctrl.Result{}, r.setCondition(
+			ctx,
+			clusterObject,
+			Condition_Ready,
+			metav1.ConditionTrue,
+			"DryRun",
+			"dry-run for resources %s: planned create of %d, update of %d, delete of %d object(s), skipped %d conflicting object(s)",
+			describeResources(resources),
+			len(plannedObjects(targets, clusterv1alpha1.TargetActionCreate)),
+			len(plannedObjects(targets, clusterv1alpha1.TargetActionUpdate)),
+			len(plannedObjects(targets, clusterv1alpha1.TargetActionDelete)),
+			clusterObject.Status.Summary.Skipped,
+		)
 	}
 
-	_log.Info("reconciled")
+	// the objects are not ready, until the rollout updated every namespace
+	if rollout := clusterObject.Status.Rollout; rollout != nil && rollout.Phase == clusterv1alpha1.RolloutPhaseProgressing {
+		return ctrl.Result{RequeueAfter: requeueAfter}, r.setCondition(
+			ctx,
+			clusterObject,
+			Condition_Ready,
+			metav1.ConditionFalse,
+			"RolloutProgressing",
+			"rollout of revision %s updated %d/%d namespace(s), %d/%d object(s) synced: %s",
+			rollout.Revision,
+			rollout.UpdatedNamespaces,
+			rollout.Namespaces,
+			clusterObject.Status.Summary.Synced,
+			clusterObject.Status.Summary.Desired,
+			rollout.Message,
+		)
+	}
+
+	// skipped namespaces are never ignored silently, they are part of the condition
+	if clusterObject.Status.Summary.Skipped > 0 {
+		return ctrl.Result{RequeueAfter: requeueAfter}, r.setCondition(
+			ctx,
+			clusterObject,
+			Condition_Ready,
+			metav1.ConditionTrue,
+			"SkippedConflicts",
+			"deployed resources %s, %d/%d object(s) synced, skipped %d conflicting object(s): %s",
+			describeResources(resources),
+			clusterObject.Status.Summary.Synced,
+			clusterObject.Status.Summary.Desired,
+			clusterObject.Status.Summary.Skipped,
+			summarizeNames(targetObjects(targets, clusterv1alpha1.TargetStateSkippedConflict)),
+		)
+	}
+
+	// objects in namespaces, which are not ready yet, are part of the condition
+	if clusterObject.Status.Summary.Pending > 0 {
+		return ctrl.Result{RequeueAfter: requeueAfter}, r.setCondition(
+			ctx,
+			clusterObject,
+			Condition_Ready,
+			metav1.ConditionTrue,
+			"PendingNamespaces",
+			"deployed resources %s, %d/%d object(s) synced, %d object(s) wait for their namespace: %s",
+			describeResources(resources),
+			clusterObject.Status.Summary.Synced,
+			clusterObject.Status.Summary.Desired,
+			clusterObject.Status.Summary.Pending,
+			summarizeNames(targetObjects(targets, clusterv1alpha1.TargetStatePending)),
+		)
+	}
 
 	r.Recorder.Eventf(
 		clusterObject,
@@ -173,51 +473,233 @@ func (r *ClusterObjectReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		"ReconciledObject",
 		"successfully cloned resource in required namespaces")
 
-	return ctrl.Result{}, r.setCondition(
+	return ctrl.Result{RequeueAfter: requeueAfter}, r.setCondition(
 		ctx,
 		clusterObject,
 		Condition_Ready,
 		metav1.ConditionTrue,
 		"DeployedResource",
-		"successfully deployed resource [%s/%s:%s]",
-		clusterObject.Replicator.Resource.GetAPIVersion(),
-		clusterObject.Replicator.Resource.GetKind(),
-		clusterObject.Replicator.Resource.GetName(),
+		"successfully deployed resources %s, %d/%d object(s) synced",
+		describeResources(resources),
+		clusterObject.Status.Summary.Synced,
+		clusterObject.Status.Summary.Desired,
 	)
 }
 
+// the result of the reconciliation of a single namespace
+type namespaceResult struct {
+	targets []clusterv1alpha1.ClusterObjectTarget
+	err     error
+}
+
+// reconcile the objects for all namespaces
+func (r *ClusterObjectReconciler) reconcileNamespaces(
+	ctx context.Context,
+	clusterObject *clusterv1alpha1.ClusterObject,
+	namespaces *corev1.NamespaceList,
+	requiredNamespaces *corev1.NamespaceList,
+	updated map[string]struct{},
+	resources []*unstructured.Unstructured,
+	stale []*unstructured.Unstructured,
+	children map[childKey]*unstructured.Unstructured) ([]clusterv1alpha1.ClusterObjectTarget, []error) {
+
+	// the required namespaces are looked up by name for every namespace
+	var required = namespaceNames(requiredNamespaces)
+
+	return r.processNamespaces(ctx, clusterObject, namespaces,
+		func(ctx context.Context, namespace corev1.Namespace) ([]clusterv1alpha1.ClusterObjectTarget, error) {
+
+			// the objects are created and updated in order. if the namespace is no target,
+			// the objects are deleted in the reverse order. stale objects are pruned last.
+			_, shouldExist := required[namespace.GetName()]
+
+			// namespaces, which wait for their step of the rollout, are not changed at all
+			if shouldExist && waitsForRollout(updated, namespace) {
+				var targets []clusterv1alpha1.ClusterObjectTarget
+				for _, typedObject := range resources {
+					if !isSource(clusterObject, namespace, typedObject) {
+						targets = append(targets, waitingTarget(namespace, typedObject))
+					}
+				}
+				return targets, nil
+			}
+
+			// objects in system namespaces, which are only no target, because they are system
+			// namespaces, are kept. they were replicated before the system namespaces were
+			// excluded, e.g. pull secrets, so they are neither updated nor pruned.
+			var keepExisting bool
+			if !shouldExist && r.isSystemNamespace(clusterObject, namespace) {
+				selected, err := r.matchesSelection(ctx, clusterObject, namespace)
+				if err != nil {
+					return nil, err
+				}
+				keepExisting = selected
+			}
+
+			var objects = resources
+			if !shouldExist {
+				objects = reverseResources(resources)
+			}
+			// the resources are shared between the workers, so appending must copy the list
+			objects = append(objects[:len(objects):len(objects)], stale...)
+
+			var targets []clusterv1alpha1.ClusterObjectTarget
+			for i, typedObject := range objects {
+
+				// stale objects are never required, the source is never replicated into its own namespace
+				var required = shouldExist && i < len(resources) && !isSource(clusterObject, namespace, typedObject)
+
+				// objects, which are not required, are only handled, if they were replicated
+				if _, ok := findChild(children, namespace.GetName(), typedObject); !required && !ok {
+					continue
+				}
+
+				// the kept objects of a system namespace are not pruned, stale objects are
+				if !required && keepExisting && i < len(resources) {
+					continue
+				}
+
+				// failed objects are only retried, after their backoff expired. the following
+				// objects depend on the failed object, so they have to wait as well.
+				if target := r.pendingRetry(clusterObject, namespace.GetName(), typedObject); target != nil {
+					log.FromContext(ctx).V(3).Info("object is waiting for retry",
+						"kind", typedObject.GetKind(),
+						"name", typedObject.GetName(),
+						"nextRetryTime", target.NextRetryTime)
+					return append(targets, *target), nil
+				}
+
+				target, err := r.reconcileObjectForNamespace(ctx, clusterObject, namespace, typedObject, required)
+				if apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause) {
+					// the namespace started terminating during the reconciliation
+					log.FromContext(ctx).V(3).Info("namespace is terminating, skipping")
+					return targets, nil
+				}
+				if err != nil {
+					return append(targets, failedTarget(namespace, typedObject, err)), err
+				}
+				if target != nil {
+					targets = append(targets, *target)
+				}
+			}
+
+			return targets, nil
+		})
+}
+
+// process all namespaces with the given function. the namespaces are processed concurrently
+// by a bounded number of workers. the results are collected in the order of the namespaces,
+// so the reported targets and errors do not depend on the order the workers finish.
+func (r *ClusterObjectReconciler) processNamespaces(
+	ctx context.Context,
+	clusterObject *clusterv1alpha1.ClusterObject,
+	namespaces *corev1.NamespaceList,
+	process func(context.Context, corev1.Namespace) ([]clusterv1alpha1.ClusterObjectTarget, error),
+) ([]clusterv1alpha1.ClusterObjectTarget, []error) {
+
+	var _log = log.FromContext(ctx)
+
+	var workers = r.MaxConcurrentNamespaces
+	if workers <= 0 {
+		workers = DefaultMaxConcurrentNamespaces
+	}
+
+	var results = make([]namespaceResult, len(namespaces.Items))
+	var semaphore = make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+dispatch:
+	for i := range namespaces.Items {
+		var namespace = namespaces.Items[i]
+
+		// no further namespaces are processed, once the manager shuts down
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case semaphore <- struct{}{}:
+		}
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			// process the objects for a specific namespace, if an error occurs, then keep the error
+			targets, err := process(
+				log.IntoContext(ctx, _log.WithValues(
+					"*clusterObject", *clusterObject,
+					"namespace.GetName()", namespace.GetName(),
+				)),
+				namespace)
+
+			results[i] = namespaceResult{targets: targets, err: err}
+		}(i)
+	}
+
+	wg.Wait()
+
+	var targets []clusterv1alpha1.ClusterObjectTarget
+	var errs []error
+	for i, result := range results {
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %w", namespaces.Items[i].GetName(), result.err))
+		}
+		targets = append(targets, result.targets...)
+	}
+
+	// the namespaces, which were not processed, are reported with a single error
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("processing of the namespaces was cancelled: %w", err))
+	}
+
+	return targets, errs
+}
+
 // ------------------------------------------------------ status functions
 
 /*
 this function checks the requested resource, wether it should exist in a namespace, or not.
+the given resource is one of the objects of the clusterobject, it is not changed.
 
 following cases should be considered:
  1. secret should not exist and does not exist -> ignore
- 2. secret should exist but does not -> create
- 3. secret should exist and it exists -> update
+ 2. secret should exist but does not -> create (server-side apply)
+ 3. secret should exist and it exists -> update (server-side apply)
  4. secret should not exist but does exist -> delete
+
+if the object exists, but is not controlled by the clusterobject, the conflict policy
+of the replicator decides, what happens with the object.
+
+the returned target contains the replication state of the object, it is nil, if the
+namespace is no target of the object.
 */
 func (r *ClusterObjectReconciler) reconcileObjectForNamespace(
 	ctx context.Context,
 	clusterObject *clusterv1alpha1.ClusterObject,
 	namespace corev1.Namespace,
-	requiredNamespaces *corev1.NamespaceList) error {
+	resource *unstructured.Unstructured,
+	shouldExist bool) (*clusterv1alpha1.ClusterObjectTarget, error) {
 
-	var _log = log.FromContext(ctx)
+	var _log = log.FromContext(ctx).WithValues("kind", resource.GetKind(), "name", resource.GetName())
+	ctx = log.IntoContext(ctx, _log)
 
 	_log.V(3).Info("check object")
 
 	// create copy of resources object
-	var typedObject = clusterObject.Replicator.Resource.DeepCopy()
+	var typedObject = resource.DeepCopy()
 
 	_log.V(5).Info("object from resources cached",
 		"*typedObject", *typedObject,
-		"clusterObject.Replicator.Resource", clusterObject.Replicator.Resource)
+		"resource", *resource)
 
 	// check, if the object does exist in the namespace and copy its content to cache
 	doesExist, err := r.objectExists(ctx, namespace.GetName(), typedObject)
 	if err != nil {
-		return r.throwOnError(
+		return nil, r.reportError(
 			ctx,
 			clusterObject,
 			err,
@@ -225,69 +707,205 @@ func (r *ClusterObjectReconciler) reconcileObjectForNamespace(
 			"error receiving the object from the cluster")
 	}
 
-	// check, if the object should exist in the namespace
-	shouldExist := r.objectShouldExist(namespace, requiredNamespaces)
 	_log.V(3).Info("state calculated", "shouldExist", shouldExist, "doesExist", doesExist)
 
+	// in dry-run mode, every write is only validated by the api server
+	var dryRun = clusterObject.Replicator.DryRun
+
 	// after calculating the current state, handle the 4 cases
 	if !shouldExist && !doesExist { // --------------------------------------------------------- case 1 -> ignore
 		_log.V(3).Info("ignoring")
-		return nil
+		return nil, nil
 	}
 
-	if shouldExist && !doesExist { // --------------------------------------------------------- case 2 -> create
-		_log.V(3).Info("creating")
+	// objects are only created in namespaces, which are ready for the replication
+	if ready, _ := namespaceReadiness(clusterObject, namespace); shouldExist && !doesExist && !ready {
+		_log.V(3).Info("waiting for the namespace to become ready")
+		return &clusterv1alpha1.ClusterObjectTarget{
+			Namespace:  namespace.GetName(),
+			APIVersion: resource.GetAPIVersion(),
+			Kind:       resource.GetKind(),
+			Name:       resource.GetName(),
+			State:      clusterv1alpha1.TargetStatePending,
+		}, nil
+	}
 
-		// create the new object, as a blueprint, to create it in the cluster
-		typedObject := clusterObject.Replicator.Resource.DeepCopy()
+	// if the object does exist, and either should be updated or deleted,
+	// check if the owner is in fact the clusterobject
+	var applyOpts []client.ApplyOption
+	var releaseController bool
+	if doesExist && metav1.IsControlledBy(typedObject, clusterObject) {
+
+		// the declared fields of a controlled object belong to the clusterobject. changes by
+		// other managers, e.g. kubectl edit, take over the ownership of the changed fields,
+		// so the ownership is forced to restore the object.
+		applyOpts = append(applyOpts, client.ForceOwnership)
+
+		// objects, which were written by older versions of r8r, are owned by the legacy
+		// field manager, until its fields are moved to the field manager of the apply
+		if !dryRun {
+			if err := r.upgradeManagedFields(ctx, typedObject); err != nil {
+				return nil, r.reportError(ctx, clusterObject, err, "ObjectUpdate", "error upgrading the managed fields of the object")
+			}
+		}
 
-		// change the namespace, to the requested namespace
-		typedObject.SetNamespace(namespace.Name)
+	} else if doesExist && !metav1.IsControlledBy(typedObject, clusterObject) {
+		_log.V(3).Info("object does not contain ownerreference")
 
-		// set the owners reference
-		// this is required for watching the dependent objects
-		if err := controllerutil.SetControllerReference(clusterObject, typedObject, r.Scheme); err != nil {
-			return r.throwOnError(ctx, clusterObject, err, "OwnerReferenceConfiguration", "unable to set owners reference")
+		// objects, which are not controlled by the clusterobject, are never deleted
+		if !shouldExist {
+			return nil, nil
 		}
 
-		// create the object in the cluster
-		if err := r.Create(ctx, typedObject, &client.CreateOptions{}); err != nil {
-			return r.throwOnError(ctx, clusterObject, err, "ObjectCreation", "error creating object in namespace")
+		// the conflict policy decides, wether the object gets taken over or not
+		opts, skipped, err := r.resolveConflict(ctx, clusterObject, typedObject)
+		if err != nil {
+			return nil, err
 		}
-	}
+		if skipped {
+			return &clusterv1alpha1.ClusterObjectTarget{
+				Namespace:       namespace.GetName(),
+				APIVersion:      resource.GetAPIVersion(),
+				Kind:            resource.GetKind(),
+				Name:            resource.GetName(),
+				State:           clusterv1alpha1.TargetStateSkippedConflict,
+				ResourceVersion: typedObject.GetResourceVersion(),
+				LastError:       "object is not controlled by the clusterobject",
+			}, nil
+		}
+		applyOpts = opts
 
-	// if the object does exist, and either should be updated or deleted,
-	// check if the owner is in fact the clusterobject
-	if !metav1.IsControlledBy(typedObject, clusterObject) {
-		_log.V(3).Info("object does not contain ownerreference")
-		return nil
+		// the controller of an overwritten object is only released before the apply, if
+		// the release is persisted
+		releaseController = dryRun && clusterObject.Replicator.ConflictPolicy == clusterv1alpha1.ConflictPolicyOverwrite
 	}
+	if dryRun {
+		applyOpts = append(applyOpts, client.DryRunAll)
+	}
+
+	if shouldExist { // ------------------------------------------------------------------------ case 2 + 3 -> create or update
+		if doesExist {
+			_log.V(3).Info("updating")
+		} else {
+			_log.V(3).Info("creating")
+		}
+
+		// remember the current object, to detect if the apply changed the object
+		var liveObject = typedObject.DeepCopy()
+
+		// create the new object, as a blueprint, to apply it in the cluster
+		if typedObject, err = r.desiredObject(clusterObject, resource, namespace); err != nil {
+			return nil, r.reportError(ctx, clusterObject, err, "ObjectRendering", "error calculating the object for the namespace")
+		}
+
+		// fill the generated keys, the generated values of the existing object are kept
+		var generatorLive *unstructured.Unstructured
+		if doesExist {
+			generatorLive = liveObject
+		}
+		generated, err := applyGenerator(clusterObject, typedObject, generatorLive)
+		if err != nil {
+			return nil, r.reportError(ctx, clusterObject, err, "SecretGeneration", "error generating the values of the secret")
+		}
+
+		// stamp the labels and annotations, which track the origin of the object
+		if err := setTrackingMetadata(clusterObject, typedObject); err != nil {
+			return nil, r.reportError(ctx, clusterObject, err, "ObjectRendering", "error calculating the tracking metadata of the object")
+		}
 
-	if shouldExist && doesExist { // --------------------------------------------------------- case 3 -> update
-		_log.V(3).Info("updating")
-		// update the values of the tempObject
-		typedObject = clusterObject.Replicator.Resource.DeepCopy()
-		typedObject.SetNamespace(namespace.Name)
+		// the ignored fields are taken from the existing object, they are not part of
+		// the content hash, so changes by other actors do not change the annotation
+		var ignored = map[string]struct{}{}
+		if doesExist {
+			if ignored, err = ignoreDifferences(clusterObject, typedObject, liveObject); err != nil {
+				return nil, r.reportError(ctx, clusterObject, err, "ObjectRendering", "error ignoring the differences of the object")
+			}
+		}
 
-		// set the owners reference again
+		// set the owners reference
 		// this is required for watching the dependent objects
 		if err := controllerutil.SetControllerReference(clusterObject, typedObject, r.Scheme); err != nil {
-			return r.throwOnError(ctx, clusterObject, err, "OwnerReferenceConfiguration", "unable to set owners reference")
+			return nil, r.reportError(ctx, clusterObject, err, "OwnerReferenceConfiguration", "unable to set owners reference")
+		}
+		if releaseController {
+			typedObject.SetOwnerReferences(append(typedObject.GetOwnerReferences(), releasedReferences(liveObject)...))
 		}
 
-		// update the object
-		if err := r.Update(ctx, typedObject, &client.UpdateOptions{}); err != nil {
-			return r.throwOnError(ctx, clusterObject, err, "ObjectUpdate", "error updating object")
+		// the keys, which are declared by the object, the other keys are removed after the apply.
+		// ignored keys of the existing object are kept as well.
+		var declared = declaredKeys(typedObject)
+		for key := range ignored {
+			declared[key] = struct{}{}
+		}
+
+		// apply the object, only the fields declared in the resource are
+		// owned by r8r, fields set by other managers stay untouched
+		if err := r.applyObject(ctx, typedObject, applyOpts...); err != nil {
+			if apierrors.IsConflict(err) {
+				return nil, r.reportError(ctx, clusterObject, err, "FieldOwnershipConflict", "server-side apply reported conflicting field managers")
+			}
+			if doesExist {
+				return nil, r.reportError(ctx, clusterObject, err, "ObjectUpdate", "error updating object")
+			}
+			return nil, r.reportError(ctx, clusterObject, err, "ObjectCreation", "error creating object in namespace")
+		}
+
+		// with the data merge strategy Replace, keys added by others are removed
+		if err := r.removeUndeclaredKeys(ctx, clusterObject, declared, typedObject); err != nil {
+			return nil, r.reportError(ctx, clusterObject, err, "ObjectUpdate", "error removing undeclared keys from object")
+		}
+
+		if dryRun {
+			return withRotation(plannedTarget(namespace, doesExist, liveObject, typedObject), generated), nil
 		}
-	}
 
-	if !shouldExist && doesExist { // --------------------------------------------------------- case 4 -> delete
-		_log.V(3).Info("deleting")
-		// delete the object
-		if err := r.Delete(ctx, typedObject, &client.DeleteOptions{}); client.IgnoreNotFound(err) != nil {
-			return r.throwOnError(ctx, clusterObject, err, "ObjectDeletion", "error deleting object")
+		var target = &clusterv1alpha1.ClusterObjectTarget{
+			Namespace:       namespace.GetName(),
+			APIVersion:      resource.GetAPIVersion(),
+			Kind:            resource.GetKind(),
+			Name:            resource.GetName(),
+			State:           clusterv1alpha1.TargetStateCreated,
+			ResourceVersion: typedObject.GetResourceVersion(),
+		}
+		if doesExist {
+			target.State = clusterv1alpha1.TargetStateUpdated
+			if liveObject.GetResourceVersion() == typedObject.GetResourceVersion() {
+				target.State = clusterv1alpha1.TargetStateInSync
+			}
 		}
+
+		return withRotation(target, generated), nil
 	}
 
-	return nil
+	// ---------------------------------------------------------------------------------------------- case 4 -> delete
+	_log.V(3).Info("deleting")
+	var deleteOpts = &client.DeleteOptions{}
+	if dryRun {
+		client.DryRunAll.ApplyToDelete(deleteOpts)
+	}
+
+	// delete the object
+	if err := r.Delete(ctx, typedObject, deleteOpts); client.IgnoreNotFound(err) != nil {
+		return nil, r.reportError(ctx, clusterObject, err, "ObjectDeletion", "error deleting object")
+	}
+
+	if dryRun {
+		return &clusterv1alpha1.ClusterObjectTarget{
+			Namespace:       namespace.GetName(),
+			APIVersion:      resource.GetAPIVersion(),
+			Kind:            resource.GetKind(),
+			Name:            resource.GetName(),
+			State:           clusterv1alpha1.TargetStatePlanned,
+			PlannedAction:   clusterv1alpha1.TargetActionDelete,
+			ResourceVersion: typedObject.GetResourceVersion(),
+		}, nil
+	}
+
+	return &clusterv1alpha1.ClusterObjectTarget{
+		Namespace:  namespace.GetName(),
+		APIVersion: resource.GetAPIVersion(),
+		Kind:       resource.GetKind(),
+		Name:       resource.GetName(),
+		State:      clusterv1alpha1.TargetStateDeleted,
+	}, nil
 }
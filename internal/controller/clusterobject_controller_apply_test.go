@@ -0,0 +1,340 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create a reconciler with a fake client, which contains the given objects. the fake
+// client tracks the managed fields, so conflicts of server-side apply are reported.
+func newFakeReconciler(t *testing.T, objs ...client.Object) (*ClusterObjectReconciler, client.Client) {
+	t.Helper()
+
+	var scheme = runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	var c = fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&clusterv1alpha1.ClusterObject{}).
+		WithReturnManagedFields().
+		Build()
+
+	return &ClusterObjectReconciler{
+		Client:   c,
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}, c
+}
+
+// create a clusterobject, which replicates a configmap into all namespaces
+func newConfigMapClusterObject(name string, data map[string]any) *clusterv1alpha1.ClusterObject {
+
+	var resource = unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "test-cm"},
+		"data":       data,
+	}}
+
+	return &clusterv1alpha1.ClusterObject{
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid"), Generation: 1},
+		Replicator: clusterv1alpha1.ClusterObjectReplicator{
+			TargetAll: true,
+			Resource:  resource,
+		},
+	}
+}
+
+// reconcile the clusterobject and return its targets
+func reconcileTargets(t *testing.T, r *ClusterObjectReconciler, name string) []clusterv1alpha1.ClusterObjectTarget {
+	t.Helper()
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	var co = &clusterv1alpha1.ClusterObject{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: name}, co); err != nil {
+		t.Fatal(err)
+	}
+
+	return co.Status.Targets
+}
+
+func TestReconcileRestoresEditedObject(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("restore", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	reconcileTargets(t, r, co.GetName())
+
+	// an edit with another field manager takes over the ownership of the changed field
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.Data["key"] = "edited"
+	if err := c.Update(ctx, cm, client.FieldOwner("kubectl-edit")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State == clusterv1alpha1.TargetStateFailed {
+			t.Fatalf("target failed: %s", target.LastError)
+		}
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["key"] != "value" {
+		t.Fatalf("expected the edited value to be restored, got %q", cm.Data["key"])
+	}
+}
+
+// create a configmap, which exists before the clusterobject and is owned by the given references
+func newExistingConfigMap(namespace string, references ...metav1.OwnerReference) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            "test-cm",
+			OwnerReferences: references,
+		},
+		Data: map[string]string{"key": "existing"},
+	}
+}
+
+func TestReconcileAdoptsObjectWithDifferentContent(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("adopt", map[string]any{"key": "value"})
+	co.Replicator.ConflictPolicy = clusterv1alpha1.ConflictPolicyAdopt
+
+	// the existing object is created with an update, so its fields are owned by another manager
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+	if err := c.Create(ctx, newExistingConfigMap("app"), client.FieldOwner("kubectl-create")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State == clusterv1alpha1.TargetStateFailed {
+			t.Fatalf("target failed: %s", target.LastError)
+		}
+	}
+
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if !metav1.IsControlledBy(cm, co) {
+		t.Fatalf("expected the object to be controlled by the clusterobject, got %+v", cm.OwnerReferences)
+	}
+	if cm.Data["key"] != "value" {
+		t.Fatalf("expected the value of the clusterobject, got %q", cm.Data["key"])
+	}
+}
+
+func TestReconcileOverwriteInDryRunKeepsOneController(t *testing.T) {
+
+	var co = newConfigMapClusterObject("overwrite", map[string]any{"key": "value"})
+	co.Replicator.ConflictPolicy = clusterv1alpha1.ConflictPolicyOverwrite
+	co.Replicator.DryRun = true
+
+	var controller = true
+	var existing = newExistingConfigMap("app", metav1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       "other",
+		UID:        "other-uid",
+		Controller: &controller,
+	})
+
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}}, existing)
+
+	// the fake client does not validate the owner references, so the applied object is inspected
+	var applied []metav1.OwnerReference
+	r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+		Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+			content, err := json.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			var typedObject = &unstructured.Unstructured{}
+			if err := typedObject.UnmarshalJSON(content); err != nil {
+				return err
+			}
+			applied = typedObject.GetOwnerReferences()
+			return c.Apply(ctx, obj, opts...)
+		},
+	})
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State != clusterv1alpha1.TargetStatePlanned || target.PlannedAction != clusterv1alpha1.TargetActionUpdate {
+			t.Fatalf("expected a planned update, got %s %s: %s", target.State, target.PlannedAction, target.LastError)
+		}
+	}
+
+	var controllers int
+	for _, reference := range applied {
+		if reference.Controller != nil && *reference.Controller {
+			controllers++
+			if reference.UID != co.GetUID() {
+				t.Fatalf("expected the clusterobject to be the controller, got %+v", reference)
+			}
+		}
+	}
+	if controllers != 1 {
+		t.Fatalf("expected exactly one controller in the applied object, got %+v", applied)
+	}
+}
+
+func TestReconcileUpgradesLegacyFieldManager(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("legacy", map[string]any{"key": "value"})
+
+	// the object was created by an older version with the legacy field manager and carries
+	// a label, which is no longer declared by the clusterobject
+	var controller = true
+	var existing = newExistingConfigMap("app", metav1.OwnerReference{
+		APIVersion: clusterv1alpha1.GroupVersion.String(),
+		Kind:       "ClusterObject",
+		Name:       co.GetName(),
+		UID:        co.GetUID(),
+		Controller: &controller,
+	})
+	existing.Labels = map[string]string{"legacy": "true"}
+
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+	if err := c.Create(ctx, existing, client.FieldOwner(legacyFieldManager)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State == clusterv1alpha1.TargetStateFailed {
+			t.Fatalf("target failed: %s", target.LastError)
+		}
+	}
+
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range cm.ManagedFields {
+		if entry.Manager == legacyFieldManager && entry.Operation == metav1.ManagedFieldsOperationUpdate {
+			t.Fatalf("expected the legacy field manager to be upgraded, got %+v", cm.ManagedFields)
+		}
+	}
+	if _, ok := cm.Labels["legacy"]; ok {
+		t.Fatalf("expected the undeclared label to be removed, got %v", cm.Labels)
+	}
+	if cm.Data["key"] != "value" {
+		t.Fatalf("expected the value of the clusterobject, got %q", cm.Data["key"])
+	}
+}
+
+func TestReconcileUpgradesLegacyFieldManagerOnce(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("legacy-once", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	reconcileTargets(t, r, co.GetName())
+
+	// after the first apply, the fields of an update with the same name as the legacy
+	// field manager belong to another actor and are kept
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.Labels["other"] = "true"
+	if err := c.Update(ctx, cm, client.FieldOwner(legacyFieldManager)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State == clusterv1alpha1.TargetStateFailed {
+			t.Fatalf("target failed: %s", target.LastError)
+		}
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Labels["other"]; !ok {
+		t.Fatalf("expected the label of the other actor to be kept, got %v", cm.Labels)
+	}
+}
+
+func TestReconcileSkipsTrackedObjectWithoutController(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("tracked", map[string]any{"key": "value"})
+	co.Replicator.ConflictPolicy = clusterv1alpha1.ConflictPolicySkip
+
+	// the tracking labels can be set by anyone, they do not make the object adoptable
+	var existing = newExistingConfigMap("app")
+	existing.Labels = map[string]string{ManagedByLabel: FieldManager, ClusterObjectLabel: co.GetName()}
+
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}}, existing)
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State != clusterv1alpha1.TargetStateSkippedConflict {
+			t.Fatalf("expected a skipped conflict, got %s: %s", target.State, target.LastError)
+		}
+	}
+
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if metav1.GetControllerOf(cm) != nil || cm.Data["key"] != "existing" {
+		t.Fatalf("expected the object to be kept as it is, got %+v", cm)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// Finalizer is the finalizer of the clusterobjects. it makes sure, that the replicated
+// objects are handled according to the deletion policy, before a clusterobject is removed.
+const Finalizer = "cluster.jnnkrdb.de/finalizer"
+
+// finalize a clusterobject, which is being deleted. depending on the deletion policy, the
+// replicated objects are either deleted or orphaned. the finalizer is only released, after
+// all replicated objects were handled.
+func (r *ClusterObjectReconciler) finalize(
+	ctx context.Context,
+	clusterObject *clusterv1alpha1.ClusterObject) error {
+
+	var _log = log.FromContext(ctx).WithValues("deletionPolicy", clusterObject.Replicator.DeletionPolicy)
+
+	if !controllerutil.ContainsFinalizer(clusterObject, Finalizer) {
+		return nil
+	}
+
+	_log.Info("finalizing")
+
+	// the replicated objects and the objects, which were not pruned yet, are
+	// handled in the reverse order of the application. if the resources can not
+	// be calculated, e.g. because the source was deleted, the inventory is used.
+	resources, err := r.desiredResources(ctx, clusterObject)
+	if err != nil {
+		_log.Info("unable to gather the resources, using the inventory", "error", err.Error())
+		resources = nil
+	}
+	var objects = append(reverseResources(resources), staleResources(clusterObject, resources)...)
+
+	// only the listed objects are handled, objects without a controller reference are skipped
+	children, err := r.listChildren(ctx, clusterObject, objects)
+	if err != nil {
+		return r.throwOnError(
+			ctx,
+			clusterObject,
+			err,
+			"ObjectGathering",
+			"error listing the replicated objects of the clusterobject")
+	}
+
+	var namespaces = &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, &client.ListOptions{}); err != nil {
+		return r.throwOnError(
+			ctx,
+			clusterObject,
+			err,
+			"NamespaceGathering",
+			"error fetching list of namespaces from cluster")
+	}
+
+	// the objects in terminating namespaces are removed with the namespace
+	namespaces = activeNamespaces(namespaces)
+
+	targets, errs := r.processNamespaces(ctx, clusterObject, namespaces,
+		func(ctx context.Context, namespace corev1.Namespace) ([]clusterv1alpha1.ClusterObjectTarget, error) {
+
+			var targets []clusterv1alpha1.ClusterObjectTarget
+			for _, typedObject := range objects {
+				child, ok := findChild(children, namespace.GetName(), typedObject)
+				if !ok {
+					continue
+				}
+
+				target, err := r.finalizeObjectForNamespace(ctx, clusterObject, namespace, child)
+				if err != nil {
+					return append(targets, failedTarget(namespace, typedObject, err)), err
+				}
+				if target != nil {
+					targets = append(targets, *target)
+				}
+			}
+
+			return targets, nil
+		})
+
+	if err := kerrors.NewAggregate(errs); err != nil {
+		return r.throwOnError(
+			ctx,
+			clusterObject,
+			err,
+			"Finalization",
+			"error cleaning up the replicated objects")
+	}
+
+	if clusterObject.Replicator.DeletionPolicy == clusterv1alpha1.DeletionPolicyOrphan {
+		r.Recorder.Eventf(clusterObject, "Normal", "OrphanedObjects",
+			"orphaned %d replicated object(s)", len(targets))
+	} else {
+		r.Recorder.Eventf(clusterObject, "Normal", "DeletedObjects",
+			"deleted %d replicated object(s)", len(targets))
+	}
+
+	// all replicated objects are handled, the clusterobject can be removed
+	controllerutil.RemoveFinalizer(clusterObject, Finalizer)
+	if err := r.Update(ctx, clusterObject, &client.UpdateOptions{}); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	_log.Info("finalized", "objects", len(targets))
+
+	return nil
+}
+
+// handle a replicated object of a single namespace according to the deletion policy. the
+// given object is the live object from the cluster, it is not changed. objects, which are
+// not controlled by the clusterobject, are left untouched.
+func (r *ClusterObjectReconciler) finalizeObjectForNamespace(
+	ctx context.Context,
+	clusterObject *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace,
+	resource *unstructured.Unstructured) (*clusterv1alpha1.ClusterObjectTarget, error) {
+
+	var _log = log.FromContext(ctx).WithValues("kind", resource.GetKind(), "name", resource.GetName())
+
+	var typedObject = resource.DeepCopy()
+
+	if !metav1.IsControlledBy(typedObject, clusterObject) {
+		return nil, nil
+	}
+
+	if clusterObject.Replicator.DeletionPolicy == clusterv1alpha1.DeletionPolicyOrphan {
+		_log.V(3).Info("orphaning")
+
+		// remove the owner reference, so the garbage collection keeps the object, and
+		// the tracking metadata, so the object is not adopted again
+		var patch = client.MergeFromWithOptions(typedObject.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+		var references []metav1.OwnerReference
+		for _, reference := range typedObject.GetOwnerReferences() {
+			if reference.UID != clusterObject.GetUID() {
+				references = append(references, reference)
+			}
+		}
+		typedObject.SetOwnerReferences(references)
+
+		// the object is no longer tracked by the clusterobject
+		removeTrackingMetadata(typedObject)
+
+		if err := r.Patch(ctx, typedObject, patch, &client.PatchOptions{FieldManager: FieldManager}); client.IgnoreNotFound(err) != nil {
+			return nil, r.reportError(ctx, clusterObject, err, "ObjectOrphaning", "error removing the owner reference from the object")
+		}
+
+		_log.Info("orphaned object")
+
+		return &clusterv1alpha1.ClusterObjectTarget{
+			Namespace:       namespace.GetName(),
+			APIVersion:      resource.GetAPIVersion(),
+			Kind:            resource.GetKind(),
+			Name:            resource.GetName(),
+			State:           clusterv1alpha1.TargetStateOrphaned,
+			ResourceVersion: typedObject.GetResourceVersion(),
+		}, nil
+	}
+
+	_log.V(3).Info("deleting")
+
+	if err := r.Delete(ctx, typedObject, &client.DeleteOptions{}); client.IgnoreNotFound(err) != nil {
+		return nil, r.reportError(ctx, clusterObject, err, "ObjectDeletion", "error deleting object")
+	}
+
+	_log.Info("deleted object")
+
+	return &clusterv1alpha1.ClusterObjectTarget{
+		Namespace:  namespace.GetName(),
+		APIVersion: resource.GetAPIVersion(),
+		Kind:       resource.GetKind(),
+		Name:       resource.GetName(),
+		State:      clusterv1alpha1.TargetStateDeleted,
+	}, nil
+}
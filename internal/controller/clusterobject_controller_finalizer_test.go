@@ -0,0 +1,279 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// count the configmaps, which are fetched one by one by the reconciler. the namespaces
+// are processed concurrently, so the counter is shared between the workers.
+func countConfigMapGets(r *ClusterObjectReconciler, c client.Client, gets *atomic.Int32) {
+	r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if obj.GetObjectKind().GroupVersionKind().Kind == "ConfigMap" {
+				gets.Add(1)
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	})
+}
+
+func TestReconcilePrunesListedObjects(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("prune", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}})
+
+	reconcileTargets(t, r, co.GetName())
+
+	// a namespace without the object is not fetched, once the selection is removed
+	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "empty"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	co.Replicator.TargetAll = false
+	if err := c.Update(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+
+	var gets atomic.Int32
+	countConfigMapGets(r, c, &gets)
+
+	var deleted int
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State == clusterv1alpha1.TargetStateDeleted {
+			deleted++
+		}
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 deleted objects, got %d", deleted)
+	}
+	if gets.Load() != 2 {
+		t.Fatalf("expected only the 2 replicated objects to be fetched, got %d", gets.Load())
+	}
+}
+
+func TestFinalizeDeletesListedObjects(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("finalize", map[string]any{"key": "value"})
+
+	// an object with the same name, which is not controlled by the clusterobject
+	var foreign = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "foreign",
+		Name:      "test-cm",
+		Labels:    map[string]string{ClusterObjectLabel: co.GetName()},
+	}}
+
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	reconcileTargets(t, r, co.GetName())
+
+	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foreign"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Create(ctx, foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+
+	var gets atomic.Int32
+	countConfigMapGets(r, c, &gets)
+
+	if err := r.finalize(ctx, co); err != nil {
+		t.Fatalf("finalize failed: %v", err)
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the replicated object to be deleted, got %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "foreign", Name: "test-cm"}, &corev1.ConfigMap{}); err != nil {
+		t.Fatalf("expected the foreign object to be kept, got %v", err)
+	}
+	if gets.Load() != 0 {
+		t.Fatalf("expected no object to be fetched one by one, got %d", gets.Load())
+	}
+}
+
+// remove the tracking labels from a replicated configmap, e.g. by a tenant
+func removeTrackingLabels(t *testing.T, c client.Client, namespace string) {
+	t.Helper()
+
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	delete(cm.Labels, ManagedByLabel)
+	delete(cm.Labels, ClusterObjectLabel)
+	if err := c.Update(context.Background(), cm, client.FieldOwner("kubectl-edit")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcilePrunesControlledObjectsWithoutLabels(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("prune-unlabeled", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	reconcileTargets(t, r, co.GetName())
+	removeTrackingLabels(t, c, "app")
+
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	co.Replicator.TargetAll = false
+	if err := c.Update(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileTargets(t, r, co.GetName())
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the controlled object without labels to be pruned, got %v", err)
+	}
+}
+
+func TestFinalizeOrphansControlledObjectsWithoutLabels(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("orphan-unlabeled", map[string]any{"key": "value"})
+	co.Replicator.DeletionPolicy = clusterv1alpha1.DeletionPolicyOrphan
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	reconcileTargets(t, r, co.GetName())
+	removeTrackingLabels(t, c, "app")
+
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.finalize(ctx, co); err != nil {
+		t.Fatalf("finalize failed: %v", err)
+	}
+
+	// the owner reference is removed, so the garbage collection keeps the object
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatalf("expected the orphaned object to be kept, got %v", err)
+	}
+	if len(cm.OwnerReferences) != 0 {
+		t.Fatalf("expected the owner reference to be removed, got %+v", cm.OwnerReferences)
+	}
+}
+
+func TestReconcileOrphansObjectsOnDeletion(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("orphan", map[string]any{"key": "value"})
+	co.Replicator.DeletionPolicy = clusterv1alpha1.DeletionPolicyOrphan
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	reconcileTargets(t, r, co.GetName())
+
+	// the fake client does not set a resource version on applied objects, which the api
+	// server always does. the orphaning relies on it for the optimistic lock.
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+
+	// the finalizer is added with the first reconciliation
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	if !controllerutil.ContainsFinalizer(co, Finalizer) {
+		t.Fatalf("expected the finalizer to be added, got %v", co.GetFinalizers())
+	}
+
+	if err := c.Delete(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: co.GetName()}}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	// the finalizer is released, after the objects were orphaned
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the clusterobject to be removed, got %v", err)
+	}
+
+	cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatalf("expected the orphaned object to be kept, got %v", err)
+	}
+	if len(cm.OwnerReferences) != 0 {
+		t.Errorf("expected the owner reference to be removed, got %+v", cm.OwnerReferences)
+	}
+	for _, label := range []string{ManagedByLabel, ClusterObjectLabel} {
+		if _, ok := cm.Labels[label]; ok {
+			t.Errorf("expected the label %s to be removed", label)
+		}
+	}
+	for _, annotation := range []string{GenerationAnnotation, ContentHashAnnotation} {
+		if _, ok := cm.Annotations[annotation]; ok {
+			t.Errorf("expected the annotation %s to be removed", annotation)
+		}
+	}
+	if cm.Data["key"] != "value" {
+		t.Errorf("expected the data to be kept, got %v", cm.Data)
+	}
+}
@@ -0,0 +1,234 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// GeneratedAtAnnotation is the annotation of a replicated Secret, which contains the time,
+// the values of the generators were generated last
+const GeneratedAtAnnotation = "cluster.jnnkrdb.de/generated-at"
+
+// the default length of a generated value
+const defaultGeneratorLength = 32
+
+// the characters of the charsets of the generators
+var generatorCharsets = map[clusterv1alpha1.GeneratorCharset]string{
+	clusterv1alpha1.GeneratorCharsetAlphanumeric: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	clusterv1alpha1.GeneratorCharsetAlphabetic:   "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	clusterv1alpha1.GeneratorCharsetNumeric:      "0123456789",
+	clusterv1alpha1.GeneratorCharsetHex:          "0123456789abcdef",
+	clusterv1alpha1.GeneratorCharsetASCII:        "!\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~",
+}
+
+// the result of the generators for a single object
+type generatorResult struct {
+	lastRotationTime *metav1.Time
+	nextRotationTime *metav1.Time
+}
+
+// find the generator of the clusterobject for the given object, if any
+func findGenerator(
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured) *clusterv1alpha1.ClusterObjectGenerator {
+
+	if typedObject.GetAPIVersion() != "v1" || typedObject.GetKind() != "Secret" {
+		return nil
+	}
+
+	for i := range co.Replicator.Generators {
+		if co.Replicator.Generators[i].SecretName == typedObject.GetName() {
+			return &co.Replicator.Generators[i]
+		}
+	}
+
+	return nil
+}
+
+// fill the generated keys of the desired object. the values of the live object are kept,
+// new values are only generated, if the live object does not contain the key, or if the
+// rotation interval passed. liveObject is nil, if the object does not exist yet.
+func applyGenerator(
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured,
+	liveObject *unstructured.Unstructured) (*generatorResult, error) {
+
+	var generator = findGenerator(co, typedObject)
+	if generator == nil {
+		return nil, nil
+	}
+
+	var now = time.Now().UTC().Truncate(time.Second)
+
+	var liveData map[string]interface{}
+	var generatedAt time.Time
+	if liveObject != nil {
+		var err error
+		if liveData, _, err = unstructured.NestedMap(liveObject.Object, "data"); err != nil {
+			return nil, err
+		}
+		if value, ok := liveObject.GetAnnotations()[GeneratedAtAnnotation]; ok {
+			if generatedAt, err = time.Parse(time.RFC3339, value); err != nil {
+				return nil, fmt.Errorf("invalid annotation %s: %w", GeneratedAtAnnotation, err)
+			}
+		}
+	}
+
+	// all keys are rotated together, after the interval passed
+	var rotate = generator.RotationInterval != nil &&
+		!generatedAt.IsZero() &&
+		!now.Before(generatedAt.Add(generator.RotationInterval.Duration))
+
+	for _, key := range generator.Keys {
+		if value, ok := liveData[key.Key]; ok && !rotate {
+			if err := unstructured.SetNestedField(typedObject.Object, value, "data", key.Key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		value, err := generateValue(key)
+		if err != nil {
+			return nil, fmt.Errorf("error generating key %q: %w", key.Key, err)
+		}
+		if err := unstructured.SetNestedField(typedObject.Object, base64.StdEncoding.EncodeToString([]byte(value)), "data", key.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	// the rotation interval starts with the first generation or the last rotation. keys,
+	// which are added later, are rotated together with the other keys.
+	if rotate || generatedAt.IsZero() {
+		generatedAt = now
+	}
+
+	// the time of the generation is part of the object, so every reconciliation
+	// declares the same annotation
+	var annotations = typedObject.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[GeneratedAtAnnotation] = generatedAt.Format(time.RFC3339)
+	typedObject.SetAnnotations(annotations)
+
+	var lastRotationTime = metav1.NewTime(generatedAt)
+	var result = &generatorResult{lastRotationTime: &lastRotationTime}
+	if generator.RotationInterval != nil {
+		var nextRotationTime = metav1.NewTime(generatedAt.Add(generator.RotationInterval.Duration))
+		result.nextRotationTime = &nextRotationTime
+	}
+
+	return result, nil
+}
+
+// add the rotation times of the generated values to a target
+func withRotation(
+	target *clusterv1alpha1.ClusterObjectTarget,
+	generated *generatorResult) *clusterv1alpha1.ClusterObjectTarget {
+
+	if generated != nil {
+		target.LastRotationTime = generated.lastRotationTime
+		target.NextRotationTime = generated.nextRotationTime
+	}
+
+	return target
+}
+
+// generate a random value for a key
+func generateValue(key clusterv1alpha1.ClusterObjectGeneratorKey) (string, error) {
+
+	var length = int(key.Length)
+	if length <= 0 {
+		length = defaultGeneratorLength
+	}
+
+	switch key.Encoding {
+
+	case clusterv1alpha1.GeneratorEncodingBase64, clusterv1alpha1.GeneratorEncodingHex:
+		var buf = make([]byte, length)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		if key.Encoding == clusterv1alpha1.GeneratorEncodingHex {
+			return hex.EncodeToString(buf), nil
+		}
+		return base64.StdEncoding.EncodeToString(buf), nil
+
+	default:
+		var charset, ok = generatorCharsets[key.Charset]
+		if !ok {
+			charset = generatorCharsets[clusterv1alpha1.GeneratorCharsetAlphanumeric]
+		}
+
+		var value = make([]byte, length)
+		for i := range value {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+			if err != nil {
+				return "", err
+			}
+			value[i] = charset[n.Int64()]
+		}
+		return string(value), nil
+	}
+}
+
+// calculate the duration until the next generated values should be rotated
+func nextRotationAfter(targets []clusterv1alpha1.ClusterObjectTarget) time.Duration {
+
+	var next time.Duration
+	for _, target := range targets {
+		if target.NextRotationTime == nil {
+			continue
+		}
+		var after = time.Until(target.NextRotationTime.Time)
+		if after < time.Second {
+			after = time.Second
+		}
+		if next == 0 || after < next {
+			next = after
+		}
+	}
+
+	return next
+}
+
+// return the earlier of two requeue durations, a duration of zero means no requeue
+func earliestRequeue(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,267 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+func TestGenerateValue(t *testing.T) {
+
+	var tests = []struct {
+		name     string
+		key      clusterv1alpha1.ClusterObjectGeneratorKey
+		length   int
+		charset  string
+		validate func(value string) error
+	}{
+		{
+			name:    "default length and charset",
+			length:  defaultGeneratorLength,
+			charset: generatorCharsets[clusterv1alpha1.GeneratorCharsetAlphanumeric],
+		},
+		{
+			name:    "numeric",
+			key:     clusterv1alpha1.ClusterObjectGeneratorKey{Length: 6, Charset: clusterv1alpha1.GeneratorCharsetNumeric},
+			length:  6,
+			charset: "0123456789",
+		},
+		{
+			name:    "explicit encoding none",
+			key:     clusterv1alpha1.ClusterObjectGeneratorKey{Length: 10, Charset: clusterv1alpha1.GeneratorCharsetHex, Encoding: clusterv1alpha1.GeneratorEncodingNone},
+			length:  10,
+			charset: "0123456789abcdef",
+		},
+		{
+			name:   "hex encoded bytes",
+			key:    clusterv1alpha1.ClusterObjectGeneratorKey{Length: 16, Encoding: clusterv1alpha1.GeneratorEncodingHex},
+			length: 32,
+			validate: func(value string) error {
+				_, err := hex.DecodeString(value)
+				return err
+			},
+		},
+		{
+			name:   "base64 encoded bytes",
+			key:    clusterv1alpha1.ClusterObjectGeneratorKey{Length: 12, Encoding: clusterv1alpha1.GeneratorEncodingBase64},
+			length: 16,
+			validate: func(value string) error {
+				_, err := base64.StdEncoding.DecodeString(value)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := generateValue(tt.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(value) != tt.length {
+				t.Errorf("expected a value with %d characters, got %q", tt.length, value)
+			}
+			for _, char := range value {
+				if tt.charset != "" && !strings.ContainsRune(tt.charset, char) {
+					t.Errorf("expected only characters of %q, got %q", tt.charset, value)
+					break
+				}
+			}
+			if tt.validate != nil {
+				if err := tt.validate(value); err != nil {
+					t.Errorf("expected a valid encoding, got %q: %v", value, err)
+				}
+			}
+		})
+	}
+}
+
+// create a secret with the given data and the time of the last generation
+func newGeneratedSecret(data map[string]any, generatedAt time.Time) *unstructured.Unstructured {
+	var secret = newResource("v1", "Secret", "credentials")
+	if data != nil {
+		secret.Object["data"] = data
+	}
+	if !generatedAt.IsZero() {
+		secret.SetAnnotations(map[string]string{GeneratedAtAnnotation: generatedAt.Format(time.RFC3339)})
+	}
+	return secret
+}
+
+func TestApplyGenerator(t *testing.T) {
+
+	var co = &clusterv1alpha1.ClusterObject{}
+	co.Replicator.Generators = []clusterv1alpha1.ClusterObjectGenerator{{
+		SecretName:       "credentials",
+		Keys:             []clusterv1alpha1.ClusterObjectGeneratorKey{{Key: "password"}},
+		RotationInterval: &metav1.Duration{Duration: time.Hour},
+	}}
+
+	var generatedAt = time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+
+	var tests = []struct {
+		name         string
+		desired      *unstructured.Unstructured
+		live         *unstructured.Unstructured
+		keepsValue   bool
+		rotated      bool
+		noGenerators bool
+	}{
+		{
+			name:    "new secret",
+			desired: newGeneratedSecret(map[string]any{"user": "dXNlcg=="}, time.Time{}),
+			rotated: true,
+		},
+		{
+			name:       "existing value is kept",
+			desired:    newGeneratedSecret(nil, time.Time{}),
+			live:       newGeneratedSecret(map[string]any{"password": "a2VlcA=="}, generatedAt),
+			keepsValue: true,
+		},
+		{
+			name:    "missing value is generated",
+			desired: newGeneratedSecret(nil, time.Time{}),
+			live:    newGeneratedSecret(map[string]any{}, generatedAt),
+		},
+		{
+			name:    "rotation after the interval",
+			desired: newGeneratedSecret(nil, time.Time{}),
+			live:    newGeneratedSecret(map[string]any{"password": "a2VlcA=="}, generatedAt.Add(-time.Hour)),
+			rotated: true,
+		},
+		{
+			name:         "other secrets",
+			desired:      newResource("v1", "Secret", "other"),
+			noGenerators: true,
+		},
+		{
+			name:         "other kinds",
+			desired:      newResource("v1", "ConfigMap", "credentials"),
+			noGenerators: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := applyGenerator(co, tt.desired, tt.live)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.noGenerators {
+				if result != nil || tt.desired.Object["data"] != nil {
+					t.Fatalf("expected no generated values, got %v", tt.desired.Object["data"])
+				}
+				return
+			}
+
+			value, _, _ := unstructured.NestedString(tt.desired.Object, "data", "password")
+			if tt.keepsValue && value != "a2VlcA==" {
+				t.Errorf("expected the existing value to be kept, got %q", value)
+			}
+			if decoded, err := base64.StdEncoding.DecodeString(value); !tt.keepsValue && (err != nil || len(decoded) != defaultGeneratorLength) {
+				t.Errorf("expected a new generated value, got %q", value)
+			}
+
+			// the interval starts with the first generation or the last rotation
+			var lastRotation = result.lastRotationTime.Time
+			if tt.rotated != lastRotation.After(generatedAt) {
+				t.Errorf("expected rotated %v, got a last rotation at %s", tt.rotated, lastRotation)
+			}
+			if !result.nextRotationTime.Time.Equal(lastRotation.Add(time.Hour)) {
+				t.Errorf("expected the next rotation one interval after %s, got %s", lastRotation, result.nextRotationTime)
+			}
+			if tt.desired.GetAnnotations()[GeneratedAtAnnotation] != lastRotation.Format(time.RFC3339) {
+				t.Errorf("expected the annotation to contain the last rotation, got %v", tt.desired.GetAnnotations())
+			}
+		})
+	}
+}
+
+func TestReconcileGeneratesValuesPerNamespace(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = &clusterv1alpha1.ClusterObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "generated", UID: "generated-uid", Generation: 1},
+		Replicator: clusterv1alpha1.ClusterObjectReplicator{
+			TargetAll: true,
+			Resource: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata":   map[string]any{"name": "credentials"},
+			}},
+			Generators: []clusterv1alpha1.ClusterObjectGenerator{{
+				SecretName: "credentials",
+				Keys:       []clusterv1alpha1.ClusterObjectGeneratorKey{{Key: "password"}},
+			}},
+		},
+	}
+
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b"}})
+
+	var passwords = func() map[string]string {
+		var values = map[string]string{}
+		for _, namespace := range []string{"a", "b"} {
+			var secret = &corev1.Secret{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "credentials"}, secret); err != nil {
+				t.Fatal(err)
+			}
+			values[namespace] = string(secret.Data["password"])
+		}
+		return values
+	}
+
+	reconcileTargets(t, r, co.GetName())
+	var first = passwords()
+	if first["a"] == "" || first["a"] == first["b"] {
+		t.Fatalf("expected a different value per namespace, got %v", first)
+	}
+
+	// the generated values are stable between the reconciliations
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.State != clusterv1alpha1.TargetStateInSync {
+			t.Errorf("expected the generated secret to be in sync, got %s: %s", target.State, target.LastError)
+		}
+		if target.LastRotationTime == nil {
+			t.Errorf("expected the last rotation time in the status, got %+v", target)
+		}
+	}
+	if second := passwords(); second["a"] != first["a"] || second["b"] != first["b"] {
+		t.Errorf("expected the generated values to be kept, got %v and %v", first, second)
+	}
+}
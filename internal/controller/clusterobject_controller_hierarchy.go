@@ -0,0 +1,171 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// DefaultParentLabel is the default label of a namespace, which contains the name of its parent namespace
+const DefaultParentLabel = "cluster.jnnkrdb.de/parent"
+
+// the maximum number of namespaces, which are listed as cycles in the status
+const maxHierarchyCycles = 50
+
+// errHierarchyCycle is returned, if the parents of a namespace form a cycle
+var errHierarchyCycle = errors.New("the parents of the namespace form a cycle")
+
+// find the name of the parent namespace of a namespace according to the hierarchy
+func parentNamespace(
+	hierarchy *clusterv1alpha1.ClusterObjectHierarchy,
+	namespace *corev1.Namespace) string {
+
+	if hierarchy.ParentAnnotation != "" {
+		return namespace.GetAnnotations()[hierarchy.ParentAnnotation]
+	}
+
+	var parentLabel = hierarchy.ParentLabel
+	if parentLabel == "" {
+		parentLabel = DefaultParentLabel
+	}
+
+	return namespace.GetLabels()[parentLabel]
+}
+
+// walk from a namespace up to the root namespace of the hierarchy. returns the depth of the
+// namespace below the root and wether the namespace is selected by the hierarchy. if the
+// parents form a cycle, errHierarchyCycle is returned.
+func walkHierarchy(
+	hierarchy *clusterv1alpha1.ClusterObjectHierarchy,
+	namespace *corev1.Namespace,
+	lookup func(name string) (*corev1.Namespace, error)) (int32, bool, error) {
+
+	var depth int32
+	var visited = map[string]struct{}{}
+	for current := namespace; ; depth++ {
+
+		if current.GetName() == hierarchy.Root {
+			var selected = (depth > 0 || !hierarchy.ExcludeRoot) &&
+				(hierarchy.MaxDepth == nil || depth <= *hierarchy.MaxDepth)
+			return depth, selected, nil
+		}
+
+		visited[current.GetName()] = struct{}{}
+
+		var parent = parentNamespace(hierarchy, current)
+		if parent == "" {
+			return depth, false, nil
+		}
+		if _, ok := visited[parent]; ok {
+			return depth, false, errHierarchyCycle
+		}
+
+		next, err := lookup(parent)
+		if err != nil {
+			return depth, false, err
+		}
+		if next == nil {
+			return depth, false, nil
+		}
+		current = next
+	}
+}
+
+// calculate the depth of a namespace below the root namespace of the hierarchy and wether
+// the namespace is selected. namespaces in a cycle are not selected, the cycles are reported
+// in the status of the clusterobject.
+func (r *ClusterObjectReconciler) hierarchyDepth(
+	ctx context.Context,
+	hierarchy *clusterv1alpha1.ClusterObjectHierarchy,
+	namespace corev1.Namespace) (int32, bool, error) {
+
+	depth, selected, err := walkHierarchy(hierarchy, &namespace, func(name string) (*corev1.Namespace, error) {
+		var parent = &corev1.Namespace{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, parent); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return parent, nil
+	})
+	if errors.Is(err, errHierarchyCycle) {
+		return depth, false, nil
+	}
+
+	return depth, selected, err
+}
+
+// calculate the status of the tree of namespaces below the root namespace
+func hierarchyStatus(
+	co *clusterv1alpha1.ClusterObject,
+	namespaces *corev1.NamespaceList) *clusterv1alpha1.ClusterObjectHierarchyStatus {
+
+	var hierarchy = co.Replicator.Hierarchy
+	if hierarchy == nil {
+		return nil
+	}
+
+	var byName = make(map[string]*corev1.Namespace, len(namespaces.Items))
+	for i := range namespaces.Items {
+		byName[namespaces.Items[i].GetName()] = &namespaces.Items[i]
+	}
+
+	var status = &clusterv1alpha1.ClusterObjectHierarchyStatus{}
+	for i := range namespaces.Items {
+		depth, selected, err := walkHierarchy(hierarchy, &namespaces.Items[i], func(name string) (*corev1.Namespace, error) {
+			return byName[name], nil
+		})
+
+		switch {
+		case errors.Is(err, errHierarchyCycle):
+			status.Cycles = append(status.Cycles, namespaces.Items[i].GetName())
+		case selected:
+			status.Namespaces++
+			status.Depth = max(status.Depth, depth)
+		}
+	}
+
+	sort.Strings(status.Cycles)
+	if len(status.Cycles) > maxHierarchyCycles {
+		status.Cycles = status.Cycles[:maxHierarchyCycles]
+	}
+
+	return status
+}
+
+// describe the cycles of the hierarchy for messages
+func describeCycles(status *clusterv1alpha1.ClusterObjectHierarchyStatus) string {
+	return fmt.Sprintf("the parents of the namespaces [%s] form a cycle", summarizeNames(status.Cycles))
+}
@@ -0,0 +1,225 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create a namespace with the given parent label
+func newChildNamespace(name, parent string) corev1.Namespace {
+	var namespace = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if parent != "" {
+		namespace.SetLabels(map[string]string{DefaultParentLabel: parent})
+	}
+	return namespace
+}
+
+// the tree of namespaces of the hierarchy tests
+//
+//	root
+//	└── team
+//	    └── app
+//	        └── feature
+//
+// loop-a and loop-b are their mutual parents, self is its own parent and orphan has a
+// parent, which does not exist
+var hierarchyNamespaces = &corev1.NamespaceList{Items: []corev1.Namespace{
+	newChildNamespace("root", ""),
+	newChildNamespace("team", "root"),
+	newChildNamespace("app", "team"),
+	newChildNamespace("feature", "app"),
+	newChildNamespace("loop-a", "loop-b"),
+	newChildNamespace("loop-b", "loop-a"),
+	newChildNamespace("self", "self"),
+	newChildNamespace("orphan", "missing"),
+	newChildNamespace("other", ""),
+}}
+
+// find a namespace of the hierarchy tests by its name
+func lookupHierarchyNamespace(name string) (*corev1.Namespace, error) {
+	for i := range hierarchyNamespaces.Items {
+		if hierarchyNamespaces.Items[i].GetName() == name {
+			return &hierarchyNamespaces.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func TestWalkHierarchy(t *testing.T) {
+
+	var tests = []struct {
+		name      string
+		hierarchy clusterv1alpha1.ClusterObjectHierarchy
+		namespace string
+		depth     int32
+		selected  bool
+		cycle     bool
+	}{
+		{
+			name:      "root",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root"},
+			namespace: "root",
+			depth:     0,
+			selected:  true,
+		},
+		{
+			name:      "excluded root",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root", ExcludeRoot: true},
+			namespace: "root",
+			depth:     0,
+		},
+		{
+			name:      "grandchild",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root"},
+			namespace: "feature",
+			depth:     3,
+			selected:  true,
+		},
+		{
+			name:      "within the maximum depth",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root", MaxDepth: ptr.To[int32](2)},
+			namespace: "app",
+			depth:     2,
+			selected:  true,
+		},
+		{
+			name:      "below the maximum depth",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root", MaxDepth: ptr.To[int32](2)},
+			namespace: "feature",
+			depth:     3,
+		},
+		{
+			name:      "subtree",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "team", ExcludeRoot: true},
+			namespace: "feature",
+			depth:     2,
+			selected:  true,
+		},
+		{
+			name:      "outside of the subtree",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "app"},
+			namespace: "team",
+			depth:     1,
+		},
+		{
+			name:      "without parent",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root"},
+			namespace: "other",
+		},
+		{
+			name:      "missing parent",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root"},
+			namespace: "orphan",
+		},
+		{
+			name:      "cycle",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root"},
+			namespace: "loop-a",
+			depth:     1,
+			cycle:     true,
+		},
+		{
+			name:      "own parent",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root"},
+			namespace: "self",
+			cycle:     true,
+		},
+		{
+			name:      "root in a cycle",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "loop-b"},
+			namespace: "loop-a",
+			depth:     1,
+			selected:  true,
+		},
+		{
+			name:      "other parent label",
+			hierarchy: clusterv1alpha1.ClusterObjectHierarchy{Root: "root", ParentLabel: "example.com/parent"},
+			namespace: "team",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, _ := lookupHierarchyNamespace(tt.namespace)
+
+			depth, selected, err := walkHierarchy(&tt.hierarchy, namespace, lookupHierarchyNamespace)
+			if cycle := errors.Is(err, errHierarchyCycle); cycle != tt.cycle {
+				t.Fatalf("expected cycle %t, got error %v", tt.cycle, err)
+			}
+			if !tt.cycle && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if depth != tt.depth || selected != tt.selected {
+				t.Errorf("expected depth %d and selected %t, got depth %d and selected %t",
+					tt.depth, tt.selected, depth, selected)
+			}
+		})
+	}
+}
+
+func TestWalkHierarchyParentAnnotation(t *testing.T) {
+
+	var hierarchy = &clusterv1alpha1.ClusterObjectHierarchy{Root: "root", ParentAnnotation: "example.com/parent"}
+
+	// the annotation takes precedence over the default label
+	var namespace = newChildNamespace("team", "other")
+	namespace.SetAnnotations(map[string]string{"example.com/parent": "root"})
+
+	depth, selected, err := walkHierarchy(hierarchy, &namespace, lookupHierarchyNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if depth != 1 || !selected {
+		t.Errorf("expected depth 1 and selected, got depth %d and selected %t", depth, selected)
+	}
+}
+
+func TestHierarchyStatus(t *testing.T) {
+
+	var co = &clusterv1alpha1.ClusterObject{}
+	if status := hierarchyStatus(co, hierarchyNamespaces); status != nil {
+		t.Fatalf("expected no status without a hierarchy, got %+v", status)
+	}
+
+	co.Replicator.Hierarchy = &clusterv1alpha1.ClusterObjectHierarchy{Root: "root", MaxDepth: ptr.To[int32](2)}
+
+	var expected = &clusterv1alpha1.ClusterObjectHierarchyStatus{
+		Namespaces: 3,
+		Depth:      2,
+		Cycles:     []string{"loop-a", "loop-b", "self"},
+	}
+	if status := hierarchyStatus(co, hierarchyNamespaces); !reflect.DeepEqual(status, expected) {
+		t.Errorf("expected %+v, got %+v", expected, status)
+	}
+}
@@ -0,0 +1,325 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// a segment of a parsed path. a segment is either the key of an object, the index
+// of an array or a wildcard, which matches all items of an array.
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parse a JSON pointer (RFC 6901) into its segments. numeric tokens are kept as keys,
+// they are resolved as indexes, if the value is an array.
+func parseJSONPointer(pointer string) ([]pathSegment, error) {
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q: must start with /", pointer)
+	}
+
+	var segments []pathSegment
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		segments = append(segments, pathSegment{key: token})
+	}
+
+	return segments, nil
+}
+
+// parse a JSONPath expression into its segments. only a subset of JSONPath is
+// supported: fields, array indexes, the wildcard [*] and quoted keys.
+func parseJSONPath(path string) ([]pathSegment, error) {
+
+	var invalid = func(reason string) error {
+		return fmt.Errorf("invalid json path %q: %s", path, reason)
+	}
+
+	// the braces of kubectl and the root $ are optional, the leading dot as well
+	var rest = strings.TrimSpace(path)
+	if strings.HasPrefix(rest, "{") && strings.HasSuffix(rest, "}") {
+		rest = rest[1 : len(rest)-1]
+	}
+	rest = strings.TrimPrefix(rest, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	var segments []pathSegment
+	for len(rest) > 0 {
+		switch rest[0] {
+
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, ".") {
+				return nil, invalid("recursive descent is not supported")
+			}
+			var end = strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, invalid("empty field name")
+			}
+			segments = append(segments, pathSegment{key: rest[:end]})
+			rest = rest[end:]
+
+		case '[':
+			var end = strings.Index(rest, "]")
+			if end < 0 {
+				return nil, invalid("missing ]")
+			}
+			var selector = rest[1:end]
+			rest = rest[end+1:]
+
+			switch {
+			case selector == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				segments = append(segments, pathSegment{key: selector[1 : len(selector)-1]})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, invalid(fmt.Sprintf("unsupported selector [%s]", selector))
+				}
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			}
+
+		default:
+			return nil, invalid(fmt.Sprintf("unexpected character %q", rest[0]))
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, invalid("the path must select a field")
+	}
+
+	return segments, nil
+}
+
+// parse all paths of an ignored difference
+func parseIgnoreDifference(ignore clusterv1alpha1.ClusterObjectIgnoreDifference) ([][]pathSegment, error) {
+
+	var paths [][]pathSegment
+	for _, pointer := range ignore.JSONPointers {
+		segments, err := parseJSONPointer(pointer)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, segments)
+	}
+	for _, path := range ignore.JSONPaths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, segments)
+	}
+
+	return paths, nil
+}
+
+// validate the ignored differences of the clusterobject
+func validateIgnoreDifferences(co *clusterv1alpha1.ClusterObject) error {
+
+	for _, ignore := range co.Replicator.IgnoreDifferences {
+		if _, err := parseIgnoreDifference(ignore); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collect the paths of the ignored differences, which apply to the given object
+func ignoredPaths(
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured) ([][]pathSegment, error) {
+
+	var paths [][]pathSegment
+	for _, ignore := range co.Replicator.IgnoreDifferences {
+		if ignore.Kind != "" && ignore.Kind != typedObject.GetKind() {
+			continue
+		}
+		if ignore.Name != "" && ignore.Name != typedObject.GetName() {
+			continue
+		}
+
+		segments, err := parseIgnoreDifference(ignore)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, segments...)
+	}
+
+	return paths, nil
+}
+
+// take the ignored fields of the desired object from the live object. fields, which do not
+// exist in the live object, are removed from the desired object, so they are not owned by r8r.
+// returns the keys of configmaps and secrets, which are ignored in the live object, so they
+// are not removed by the data merge strategy.
+func ignoreDifferences(
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured,
+	liveObject *unstructured.Unstructured) (map[string]struct{}, error) {
+
+	paths, err := ignoredPaths(co, typedObject)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys = map[string]struct{}{}
+	for _, segments := range paths {
+		for _, path := range expandPath(typedObject.Object, segments, nil) {
+			if value, ok := lookupPath(liveObject.Object, path); ok {
+				setPath(typedObject.Object, path, value)
+			} else {
+				removePath(typedObject.Object, path)
+			}
+		}
+
+		for _, path := range expandPath(liveObject.Object, segments, nil) {
+			if len(path) == 2 && slices.Contains(dataFields, path[0].key) {
+				keys[path[1].key] = struct{}{}
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// expand the segments of a path against a value into concrete paths, which exist in the value
+func expandPath(value interface{}, segments []pathSegment, prefix []pathSegment) [][]pathSegment {
+
+	if len(segments) == 0 {
+		return [][]pathSegment{prefix}
+	}
+
+	var segment = segments[0]
+	var next = func(child interface{}, concrete pathSegment) [][]pathSegment {
+		return expandPath(child, segments[1:], append(append([]pathSegment{}, prefix...), concrete))
+	}
+
+	switch typedValue := value.(type) {
+
+	case map[string]interface{}:
+		if segment.wildcard || segment.isIndex {
+			return nil
+		}
+		if child, ok := typedValue[segment.key]; ok {
+			return next(child, pathSegment{key: segment.key})
+		}
+
+	case []interface{}:
+		if segment.wildcard {
+			var paths [][]pathSegment
+			for i, child := range typedValue {
+				paths = append(paths, next(child, pathSegment{index: i, isIndex: true})...)
+			}
+			return paths
+		}
+
+		var index, isIndex = segment.index, segment.isIndex
+		if !isIndex {
+			var err error
+			if index, err = strconv.Atoi(segment.key); err == nil {
+				isIndex = true
+			}
+		}
+		if isIndex && index >= 0 && index < len(typedValue) {
+			return next(typedValue[index], pathSegment{index: index, isIndex: true})
+		}
+	}
+
+	return nil
+}
+
+// find the value of a concrete path
+func lookupPath(value interface{}, path []pathSegment) (interface{}, bool) {
+
+	for _, segment := range path {
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			child, ok := typedValue[segment.key]
+			if segment.isIndex || !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			if !segment.isIndex || segment.index >= len(typedValue) {
+				return nil, false
+			}
+			value = typedValue[segment.index]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// set the value of a concrete path, which exists in the given value
+func setPath(value interface{}, path []pathSegment, newValue interface{}) {
+
+	parent, ok := lookupPath(value, path[:len(path)-1])
+	if !ok {
+		return
+	}
+
+	var last = path[len(path)-1]
+	switch typedParent := parent.(type) {
+	case map[string]interface{}:
+		typedParent[last.key] = newValue
+	case []interface{}:
+		typedParent[last.index] = newValue
+	}
+}
+
+// remove the value of a concrete path. items of arrays are not removed, since this
+// would change the indexes of the following items.
+func removePath(value interface{}, path []pathSegment) {
+
+	parent, ok := lookupPath(value, path[:len(path)-1])
+	if !ok {
+		return
+	}
+
+	if typedParent, ok := parent.(map[string]interface{}); ok {
+		delete(typedParent, path[len(path)-1].key)
+	}
+}
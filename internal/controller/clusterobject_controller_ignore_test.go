@@ -0,0 +1,322 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create the segment of a key
+func keySegment(key string) pathSegment {
+	return pathSegment{key: key}
+}
+
+// create the segment of an array index
+func indexSegment(index int) pathSegment {
+	return pathSegment{index: index, isIndex: true}
+}
+
+func TestParseJSONPointer(t *testing.T) {
+
+	var tests = []struct {
+		name     string
+		pointer  string
+		expected []pathSegment
+		invalid  bool
+	}{
+		{
+			name:     "fields",
+			pointer:  "/spec/replicas",
+			expected: []pathSegment{keySegment("spec"), keySegment("replicas")},
+		},
+		{
+			name:     "escaped slash",
+			pointer:  "/metadata/annotations/example.com~1owner",
+			expected: []pathSegment{keySegment("metadata"), keySegment("annotations"), keySegment("example.com/owner")},
+		},
+		{
+			name:     "escaped tilde",
+			pointer:  "/data/a~0b",
+			expected: []pathSegment{keySegment("data"), keySegment("a~b")},
+		},
+		{
+			name:     "escaped tilde before a one",
+			pointer:  "/data/a~01",
+			expected: []pathSegment{keySegment("data"), keySegment("a~1")},
+		},
+		{
+			name:     "numeric token is kept as key",
+			pointer:  "/spec/containers/0",
+			expected: []pathSegment{keySegment("spec"), keySegment("containers"), keySegment("0")},
+		},
+		{
+			name:    "missing leading slash",
+			pointer: "spec/replicas",
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := parseJSONPointer(tt.pointer)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", segments)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(segments, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, segments)
+			}
+		})
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+
+	var tests = []struct {
+		name     string
+		path     string
+		expected []pathSegment
+		invalid  bool
+	}{
+		{
+			name:     "fields",
+			path:     ".spec.replicas",
+			expected: []pathSegment{keySegment("spec"), keySegment("replicas")},
+		},
+		{
+			name:     "root and braces",
+			path:     "{$.spec.replicas}",
+			expected: []pathSegment{keySegment("spec"), keySegment("replicas")},
+		},
+		{
+			name:     "without leading dot",
+			path:     "spec.replicas",
+			expected: []pathSegment{keySegment("spec"), keySegment("replicas")},
+		},
+		{
+			name:     "array index",
+			path:     ".spec.containers[1].image",
+			expected: []pathSegment{keySegment("spec"), keySegment("containers"), indexSegment(1), keySegment("image")},
+		},
+		{
+			name:     "wildcard",
+			path:     ".spec.containers[*].image",
+			expected: []pathSegment{keySegment("spec"), keySegment("containers"), {wildcard: true}, keySegment("image")},
+		},
+		{
+			name:     "quoted keys",
+			path:     `.metadata.annotations['example.com/owner']["a.b"]`,
+			expected: []pathSegment{keySegment("metadata"), keySegment("annotations"), keySegment("example.com/owner"), keySegment("a.b")},
+		},
+		{
+			name:    "empty path",
+			path:    "$",
+			invalid: true,
+		},
+		{
+			name:    "recursive descent",
+			path:    "$..image",
+			invalid: true,
+		},
+		{
+			name:    "empty field",
+			path:    ".spec.",
+			invalid: true,
+		},
+		{
+			name:    "missing bracket",
+			path:    ".spec.containers[0",
+			invalid: true,
+		},
+		{
+			name:    "negative index",
+			path:    ".spec.containers[-1]",
+			invalid: true,
+		},
+		{
+			name:    "filter",
+			path:    ".spec.containers[?(@.name=='app')]",
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := parseJSONPath(tt.path)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", segments)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(segments, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, segments)
+			}
+		})
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+
+	var value = map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:1"},
+				map[string]interface{}{"name": "sidecar"},
+			},
+		},
+	}
+
+	var tests = []struct {
+		name     string
+		path     string
+		expected [][]pathSegment
+	}{
+		{
+			name:     "field",
+			path:     ".spec.replicas",
+			expected: [][]pathSegment{{keySegment("spec"), keySegment("replicas")}},
+		},
+		{
+			name:     "array index",
+			path:     ".spec.containers[0].image",
+			expected: [][]pathSegment{{keySegment("spec"), keySegment("containers"), indexSegment(0), keySegment("image")}},
+		},
+		{
+			name:     "numeric key of a pointer resolves as index",
+			path:     "/spec/containers/1/name",
+			expected: [][]pathSegment{{keySegment("spec"), keySegment("containers"), indexSegment(1), keySegment("name")}},
+		},
+		{
+			name: "wildcard",
+			path: ".spec.containers[*].name",
+			expected: [][]pathSegment{
+				{keySegment("spec"), keySegment("containers"), indexSegment(0), keySegment("name")},
+				{keySegment("spec"), keySegment("containers"), indexSegment(1), keySegment("name")},
+			},
+		},
+		{
+			name:     "wildcard skips missing fields",
+			path:     ".spec.containers[*].image",
+			expected: [][]pathSegment{{keySegment("spec"), keySegment("containers"), indexSegment(0), keySegment("image")}},
+		},
+		{
+			name: "missing field",
+			path: ".spec.paused",
+		},
+		{
+			name: "index out of range",
+			path: ".spec.containers[2].name",
+		},
+		{
+			name: "index on an object",
+			path: ".spec[0]",
+		},
+		{
+			name: "wildcard on an object",
+			path: ".spec[*]",
+		},
+		{
+			name: "field of a scalar",
+			path: ".spec.replicas.value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var segments []pathSegment
+			var err error
+			if tt.path[0] == '/' {
+				segments, err = parseJSONPointer(tt.path)
+			} else {
+				segments, err = parseJSONPath(tt.path)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var paths = expandPath(value, segments, nil)
+			if !reflect.DeepEqual(paths, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, paths)
+			}
+		})
+	}
+}
+
+func TestIgnoreDifferences(t *testing.T) {
+
+	var co = &clusterv1alpha1.ClusterObject{}
+	co.Replicator.IgnoreDifferences = []clusterv1alpha1.ClusterObjectIgnoreDifference{
+		{Kind: "Deployment", JSONPaths: []string{".spec.replicas", ".spec.template.spec.containers[*].image"}},
+		{Kind: "Secret", JSONPointers: []string{"/metadata/annotations/example.com~1owner"}},
+	}
+
+	var desired = &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:1"},
+			}}},
+		},
+	}}
+	var live = &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:2"},
+			}}},
+		},
+	}}
+
+	if _, err := ignoreDifferences(co, desired, live); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the field, which is missing in the live object, is no longer declared
+	if _, ok, _ := unstructured.NestedFieldNoCopy(desired.Object, "spec", "replicas"); ok {
+		t.Errorf("expected the replicas to be removed")
+	}
+
+	// the field of the live object is kept
+	containers, _, _ := unstructured.NestedSlice(desired.Object, "spec", "template", "spec", "containers")
+	if image := containers[0].(map[string]interface{})["image"]; image != "app:2" {
+		t.Errorf("expected the live image to be kept, got %v", image)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// the fields of configmaps and secrets, which contain the keys
+var dataFields = []string{"data", "binaryData"}
+
+// validate wether the keys of an object are handled by the data merge strategy
+func hasDataKeys(typedObject *unstructured.Unstructured) bool {
+	return typedObject.GetAPIVersion() == "v1" &&
+		(typedObject.GetKind() == "ConfigMap" || typedObject.GetKind() == "Secret")
+}
+
+// collect the keys, which are declared by the desired object. the keys of
+// stringData are part of data after the write.
+func declaredKeys(typedObject *unstructured.Unstructured) map[string]struct{} {
+
+	var keys = map[string]struct{}{}
+	for _, field := range append([]string{"stringData"}, dataFields...) {
+		values, _, _ := unstructured.NestedMap(typedObject.Object, field)
+		for key := range values {
+			keys[key] = struct{}{}
+		}
+	}
+
+	return keys
+}
+
+// remove the keys of the applied object, which are not part of the declared keys, if the
+// data merge strategy is Replace. server-side apply only removes the keys, which were
+// owned by r8r, keys added by others are removed explicitly.
+func (r *ClusterObjectReconciler) removeUndeclaredKeys(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	declared map[string]struct{},
+	appliedObject *unstructured.Unstructured) error {
+
+	if co.Replicator.DataMergeStrategy == clusterv1alpha1.DataMergeStrategyMerge || !hasDataKeys(appliedObject) {
+		return nil
+	}
+
+	var patch = client.MergeFromWithOptions(appliedObject.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	var removed []string
+	for _, field := range dataFields {
+		values, _, err := unstructured.NestedMap(appliedObject.Object, field)
+		if err != nil {
+			return err
+		}
+		for key := range values {
+			if _, ok := declared[key]; !ok {
+				unstructured.RemoveNestedField(appliedObject.Object, field, key)
+				removed = append(removed, key)
+			}
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	log.FromContext(ctx).V(3).Info("removing undeclared keys", "keys", removed)
+
+	var patchOpts = &client.PatchOptions{FieldManager: FieldManager}
+	if co.Replicator.DryRun {
+		client.DryRunAll.ApplyToPatch(patchOpts)
+	}
+
+	return r.Patch(ctx, appliedObject, patch, patchOpts)
+}
@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+func TestDeclaredKeys(t *testing.T) {
+
+	var secret = newResource("v1", "Secret", "credentials")
+	secret.Object["data"] = map[string]any{"a": "MQ=="}
+	secret.Object["binaryData"] = map[string]any{"b": "Mg=="}
+	secret.Object["stringData"] = map[string]any{"c": "3"}
+
+	var expected = map[string]struct{}{"a": {}, "b": {}, "c": {}}
+	if keys := declaredKeys(secret); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected the keys %v, got %v", expected, keys)
+	}
+}
+
+func TestReconcileDataMergeStrategy(t *testing.T) {
+
+	var tests = []struct {
+		name      string
+		strategy  clusterv1alpha1.DataMergeStrategy
+		keepsKeys bool
+	}{
+		{name: "default replaces the keys", keepsKeys: false},
+		{name: "replace", strategy: clusterv1alpha1.DataMergeStrategyReplace, keepsKeys: false},
+		{name: "merge", strategy: clusterv1alpha1.DataMergeStrategyMerge, keepsKeys: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx = context.Background()
+			var co = newConfigMapClusterObject("merge", map[string]any{"declared": "value", "removed": "value"})
+			co.Replicator.DataMergeStrategy = tt.strategy
+			r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+			reconcileTargets(t, r, co.GetName())
+
+			// a tenant adds a key to the replicated object
+			var cm = &corev1.ConfigMap{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+				t.Fatal(err)
+			}
+			cm.Data["tenant"] = "value"
+			if err := c.Update(ctx, cm, client.FieldOwner("tenant")); err != nil {
+				t.Fatal(err)
+			}
+
+			// a key is removed from the clusterobject
+			if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+				t.Fatal(err)
+			}
+			co.Replicator.Resource.Object["data"] = map[string]any{"declared": "value"}
+			if err := c.Update(ctx, co); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, target := range reconcileTargets(t, r, co.GetName()) {
+				if target.State == clusterv1alpha1.TargetStateFailed {
+					t.Fatalf("target failed: %s", target.LastError)
+				}
+			}
+
+			if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+				t.Fatal(err)
+			}
+			var expected = map[string]string{"declared": "value"}
+			if tt.keepsKeys {
+				expected["tenant"] = "value"
+			}
+			if !reflect.DeepEqual(cm.Data, expected) {
+				t.Errorf("expected the keys %v, got %v", expected, cm.Data)
+			}
+		})
+	}
+}
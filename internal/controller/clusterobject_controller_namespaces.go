@@ -0,0 +1,241 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// IgnoreNamespaceAnnotation opts a namespace out of the replication. The value "true" or "*"
+// opts the namespace out of all clusterobjects, otherwise the value is a comma separated list
+// of the names of the clusterobjects, which are ignored.
+const IgnoreNamespaceAnnotation = "cluster.jnnkrdb.de/ignore"
+
+// validate wether a namespace matches the labelselector and the names of the replicator.
+// both are combined with the operator of the names, a missing selection is not evaluated.
+func matchesLabelsAndNames(
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) (bool, error) {
+
+	var matchesLabels bool
+	if co.Replicator.LabelSelector != nil {
+		labelselector, err := metav1.LabelSelectorAsSelector(co.Replicator.LabelSelector)
+		if err != nil {
+			return false, err
+		}
+		matchesLabels = labelselector.Matches(labels.Set(namespace.GetLabels()))
+	}
+
+	var names = co.Replicator.Namespaces
+	if names == nil {
+		return matchesLabels, nil
+	}
+
+	matchesNames, err := matchesNamespaceNames(names, namespace.GetName())
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case co.Replicator.LabelSelector == nil:
+		return matchesNames, nil
+	case names.Operator == clusterv1alpha1.SelectorOperatorOr:
+		return matchesLabels || matchesNames, nil
+	default:
+		return matchesLabels && matchesNames, nil
+	}
+}
+
+// validate wether the name of a namespace matches any of the names, globs or
+// regular expressions of the namespace selection
+func matchesNamespaceNames(
+	namespaces *clusterv1alpha1.ClusterObjectNamespaces,
+	name string) (bool, error) {
+
+	if slices.Contains(namespaces.Names, name) {
+		return true, nil
+	}
+
+	for _, glob := range namespaces.Globs {
+		matches, err := path.Match(glob, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+		if matches {
+			return true, nil
+		}
+	}
+
+	for _, expression := range namespaces.Regexes {
+		// the expression must match the whole name
+		regex, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", expression))
+		if err != nil {
+			return false, fmt.Errorf("invalid regex %q: %w", expression, err)
+		}
+		if regex.MatchString(name) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// validate wether a namespace is excluded from the clusterobject, either by the exclusions
+// of the replicator or by the ignore annotation of the namespace
+func namespaceExcluded(
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) (bool, error) {
+
+	if ignoresClusterObject(namespace, co.GetName()) {
+		return true, nil
+	}
+
+	for _, glob := range co.Replicator.ExcludeNamespaces {
+		matches, err := path.Match(glob, namespace.GetName())
+		if err != nil {
+			return false, fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+		if matches {
+			return true, nil
+		}
+	}
+
+	if co.Replicator.ExcludeSelector != nil {
+		excludeSelector, err := metav1.LabelSelectorAsSelector(co.Replicator.ExcludeSelector)
+		if err != nil {
+			return false, err
+		}
+		if excludeSelector.Matches(labels.Set(namespace.GetLabels())) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// validate wether the ignore annotation of a namespace opts the namespace
+// out of the clusterobject with the given name
+func ignoresClusterObject(
+	namespace corev1.Namespace,
+	name string) bool {
+
+	var value = strings.TrimSpace(namespace.GetAnnotations()[IgnoreNamespaceAnnotation])
+
+	switch value {
+	case "", "false":
+		return false
+	case "true", "*":
+		return true
+	}
+
+	for _, ignored := range strings.Split(value, ",") {
+		if strings.TrimSpace(ignored) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove the namespaces, which are being deleted. objects can not be created in terminating
+// namespaces and the objects in them are removed with the namespace anyway.
+func activeNamespaces(namespaces *corev1.NamespaceList) *corev1.NamespaceList {
+
+	var active = &corev1.NamespaceList{}
+	for _, namespace := range namespaces.Items {
+		if namespace.Status.Phase == corev1.NamespaceTerminating || !namespace.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		active.Items = append(active.Items, namespace)
+	}
+
+	return active
+}
+
+// calculate the duration, until a namespace is ready for the replication according to
+// the namespaceReadiness of the replicator. returns wether the namespace is ready and
+// the duration until the minimum age is reached, if the namespace is too young.
+func namespaceReadiness(
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) (bool, time.Duration) {
+
+	var readiness = co.Replicator.NamespaceReadiness
+	if readiness == nil {
+		return true, 0
+	}
+
+	var ready = true
+	var after time.Duration
+
+	if readiness.MinAge != nil {
+		if age := time.Since(namespace.GetCreationTimestamp().Time); age < readiness.MinAge.Duration {
+			ready = false
+			after = readiness.MinAge.Duration - age
+		}
+	}
+
+	if readiness.Label != "" {
+		if _, ok := namespace.GetLabels()[readiness.Label]; !ok {
+			ready = false
+		}
+	}
+
+	return ready, after
+}
+
+// calculate the duration until the next required namespace reaches its minimum age
+func nextReadinessAfter(
+	co *clusterv1alpha1.ClusterObject,
+	requiredNamespaces *corev1.NamespaceList) time.Duration {
+
+	var next time.Duration
+	for _, namespace := range requiredNamespaces.Items {
+		if _, after := namespaceReadiness(co, namespace); after > 0 && (next == 0 || after < next) {
+			next = after
+		}
+	}
+
+	return next
+}
+
+// validate wether the replicator of the clusterobject selects namespaces at all
+func hasNamespaceSelection(co *clusterv1alpha1.ClusterObject) bool {
+	return co.Replicator.TargetAll ||
+		co.Replicator.LabelSelector != nil ||
+		co.Replicator.Namespaces != nil ||
+		co.Replicator.NamespaceCEL != "" ||
+		co.Replicator.Hierarchy != nil
+}
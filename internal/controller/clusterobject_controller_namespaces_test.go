@@ -0,0 +1,495 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+func TestMatchesNamespaceNames(t *testing.T) {
+
+	var tests = []struct {
+		name       string
+		namespaces clusterv1alpha1.ClusterObjectNamespaces
+		namespace  string
+		matches    bool
+		invalid    bool
+	}{
+		{
+			name:       "exact name",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Names: []string{"team-a", "team-b"}},
+			namespace:  "team-b",
+			matches:    true,
+		},
+		{
+			name:       "names are no globs",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Names: []string{"team-*"}},
+			namespace:  "team-a",
+		},
+		{
+			name:       "glob with star",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"team-*"}},
+			namespace:  "team-frontend",
+			matches:    true,
+		},
+		{
+			name:       "glob matches the whole name",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"team-*"}},
+			namespace:  "my-team-frontend",
+		},
+		{
+			name:       "glob with question mark",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"env-?"}},
+			namespace:  "env-1",
+			matches:    true,
+		},
+		{
+			name:       "glob with question mark requires one character",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"env-?"}},
+			namespace:  "env-10",
+		},
+		{
+			name:       "glob with character class",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"env-[a-c]"}},
+			namespace:  "env-b",
+			matches:    true,
+		},
+		{
+			name:       "glob with negated character class",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"env-[^a-c]"}},
+			namespace:  "env-b",
+		},
+		{
+			name:       "any of the globs",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"dev-*", "prod-*"}},
+			namespace:  "prod-eu",
+			matches:    true,
+		},
+		{
+			name:       "invalid glob",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"env-["}},
+			namespace:  "env-a",
+			invalid:    true,
+		},
+		{
+			name:       "regex",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Regexes: []string{"team-(a|b)"}},
+			namespace:  "team-a",
+			matches:    true,
+		},
+		{
+			name:       "regex matches the whole name",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Regexes: []string{"team-(a|b)"}},
+			namespace:  "team-ab",
+		},
+		{
+			name:       "invalid regex",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{Regexes: []string{"team-("}},
+			namespace:  "team-a",
+			invalid:    true,
+		},
+		{
+			name:       "empty selection",
+			namespaces: clusterv1alpha1.ClusterObjectNamespaces{},
+			namespace:  "team-a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := matchesNamespaceNames(&tt.namespaces, tt.namespace)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if matches != tt.matches {
+				t.Errorf("expected %t, got %t", tt.matches, matches)
+			}
+		})
+	}
+}
+
+func TestMatchesLabelsAndNames(t *testing.T) {
+
+	var selector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+	var names = func(operator clusterv1alpha1.SelectorOperator) *clusterv1alpha1.ClusterObjectNamespaces {
+		return &clusterv1alpha1.ClusterObjectNamespaces{Globs: []string{"team-*"}, Operator: operator}
+	}
+
+	var tests = []struct {
+		name      string
+		selector  *metav1.LabelSelector
+		names     *clusterv1alpha1.ClusterObjectNamespaces
+		namespace string
+		labels    map[string]string
+		matches   bool
+	}{
+		{
+			name:      "no selection",
+			namespace: "team-a",
+		},
+		{
+			name:      "only labels",
+			selector:  selector,
+			namespace: "other",
+			labels:    map[string]string{"env": "prod"},
+			matches:   true,
+		},
+		{
+			name:      "only names",
+			names:     names(""),
+			namespace: "team-a",
+			matches:   true,
+		},
+		{
+			name:      "and requires both",
+			selector:  selector,
+			names:     names(clusterv1alpha1.SelectorOperatorAnd),
+			namespace: "team-a",
+		},
+		{
+			name:      "and matches both",
+			selector:  selector,
+			names:     names(""),
+			namespace: "team-a",
+			labels:    map[string]string{"env": "prod"},
+			matches:   true,
+		},
+		{
+			name:      "or matches the names",
+			selector:  selector,
+			names:     names(clusterv1alpha1.SelectorOperatorOr),
+			namespace: "team-a",
+			matches:   true,
+		},
+		{
+			name:      "or matches the labels",
+			selector:  selector,
+			names:     names(clusterv1alpha1.SelectorOperatorOr),
+			namespace: "other",
+			labels:    map[string]string{"env": "prod"},
+			matches:   true,
+		},
+		{
+			name:      "or matches neither",
+			selector:  selector,
+			names:     names(clusterv1alpha1.SelectorOperatorOr),
+			namespace: "other",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var co = &clusterv1alpha1.ClusterObject{}
+			co.Replicator.LabelSelector = tt.selector
+			co.Replicator.Namespaces = tt.names
+
+			var namespace = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace, Labels: tt.labels}}
+
+			matches, err := matchesLabelsAndNames(co, namespace)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if matches != tt.matches {
+				t.Errorf("expected %t, got %t", tt.matches, matches)
+			}
+		})
+	}
+}
+
+func TestReconcileKeepsObjectsInSystemNamespaces(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("system", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}})
+
+	// the object was replicated into the system namespace by an older version
+	reconcileTargets(t, r, co.GetName())
+
+	r.SystemNamespaces = []string{"kube-system"}
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.Namespace == "kube-system" {
+			t.Fatalf("expected no target in the system namespace, got %s", target.State)
+		}
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "test-cm"}, &corev1.ConfigMap{}); err != nil {
+		t.Fatalf("expected the object in the system namespace to be kept, got %v", err)
+	}
+}
+
+func TestReconcilePrunesDeselectedSystemNamespaces(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("system-names", map[string]any{"key": "value"})
+	co.Replicator.TargetAll = false
+	co.Replicator.Namespaces = &clusterv1alpha1.ClusterObjectNamespaces{Names: []string{"app", "kube-system"}}
+
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}})
+	r.SystemNamespaces = []string{"kube-system"}
+
+	reconcileTargets(t, r, co.GetName())
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "test-cm"}, &corev1.ConfigMap{}); err != nil {
+		t.Fatalf("expected the system namespace selected by its name to be a target, got %v", err)
+	}
+
+	// a system namespace, which is removed from the names, is no longer selected at all
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	co.Replicator.Namespaces.Names = []string{"app"}
+	if err := c.Update(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileTargets(t, r, co.GetName())
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the object in the deselected system namespace to be pruned, got %v", err)
+	}
+}
+
+func TestNamespaceExcluded(t *testing.T) {
+
+	var tests = []struct {
+		name        string
+		exclude     []string
+		selector    *metav1.LabelSelector
+		labels      map[string]string
+		annotations map[string]string
+		expected    bool
+		expectErr   bool
+	}{
+		{name: "no exclusions"},
+		{name: "excluded by glob", exclude: []string{"team-*"}, expected: true},
+		{name: "not excluded by glob", exclude: []string{"other-*"}},
+		{name: "invalid glob", exclude: []string{"[team"}, expectErr: true},
+		{
+			name:     "excluded by selector",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"replication": "off"}},
+			labels:   map[string]string{"replication": "off"},
+			expected: true,
+		},
+		{
+			name:     "not excluded by selector",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"replication": "off"}},
+			labels:   map[string]string{"replication": "on"},
+		},
+		{name: "ignores all clusterobjects", annotations: map[string]string{IgnoreNamespaceAnnotation: "true"}, expected: true},
+		{name: "ignores all clusterobjects with a wildcard", annotations: map[string]string{IgnoreNamespaceAnnotation: "*"}, expected: true},
+		{name: "ignores the clusterobject by name", annotations: map[string]string{IgnoreNamespaceAnnotation: "other, excluded"}, expected: true},
+		{name: "ignores other clusterobjects", annotations: map[string]string{IgnoreNamespaceAnnotation: "other"}},
+		{name: "ignore disabled", annotations: map[string]string{IgnoreNamespaceAnnotation: "false"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var co = &clusterv1alpha1.ClusterObject{ObjectMeta: metav1.ObjectMeta{Name: "excluded"}}
+			co.Replicator.ExcludeNamespaces = tt.exclude
+			co.Replicator.ExcludeSelector = tt.selector
+
+			var namespace = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-a",
+				Labels:      tt.labels,
+				Annotations: tt.annotations,
+			}}
+
+			excluded, err := namespaceExcluded(co, namespace)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if excluded != tt.expected {
+				t.Errorf("expected excluded %v, got %v", tt.expected, excluded)
+			}
+		})
+	}
+}
+
+func TestReconcilePrunesExcludedNamespaces(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("exclusions", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sandbox"}})
+
+	reconcileTargets(t, r, co.GetName())
+
+	// an exclusion wins over targetAll, so the object is removed from the excluded namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	co.Replicator.ExcludeNamespaces = []string{"sand*"}
+	if err := c.Update(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.Namespace == "sandbox" && target.State != clusterv1alpha1.TargetStateDeleted {
+			t.Errorf("expected the object in the excluded namespace to be deleted, got %s", target.State)
+		}
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "sandbox", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the object in the excluded namespace to be deleted, got %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, &corev1.ConfigMap{}); err != nil {
+		t.Errorf("expected the object in the other namespace to be kept, got %v", err)
+	}
+}
+
+func TestActiveNamespaces(t *testing.T) {
+
+	var deleted = metav1.Now()
+	var namespaces = &corev1.NamespaceList{Items: []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "active"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "terminating"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "deleted", DeletionTimestamp: &deleted}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "new"}},
+	}}
+
+	var active = activeNamespaces(namespaces)
+	if len(active.Items) != 2 || active.Items[0].GetName() != "active" || active.Items[1].GetName() != "new" {
+		t.Errorf("expected the namespaces active and new, got %+v", active.Items)
+	}
+}
+
+func TestNamespaceReadiness(t *testing.T) {
+
+	var tests = []struct {
+		name      string
+		readiness *clusterv1alpha1.ClusterObjectNamespaceReadiness
+		age       time.Duration
+		labels    map[string]string
+		ready     bool
+		after     time.Duration
+	}{
+		{name: "no readiness", ready: true},
+		{
+			name:      "old enough",
+			readiness: &clusterv1alpha1.ClusterObjectNamespaceReadiness{MinAge: &metav1.Duration{Duration: time.Minute}},
+			age:       time.Hour,
+			ready:     true,
+		},
+		{
+			name:      "too young",
+			readiness: &clusterv1alpha1.ClusterObjectNamespaceReadiness{MinAge: &metav1.Duration{Duration: time.Hour}},
+			age:       10 * time.Minute,
+			after:     50 * time.Minute,
+		},
+		{
+			name:      "label present",
+			readiness: &clusterv1alpha1.ClusterObjectNamespaceReadiness{Label: "ready"},
+			labels:    map[string]string{"ready": ""},
+			ready:     true,
+		},
+		{
+			name:      "label missing",
+			readiness: &clusterv1alpha1.ClusterObjectNamespaceReadiness{Label: "ready"},
+		},
+		{
+			name: "old enough without the label",
+			readiness: &clusterv1alpha1.ClusterObjectNamespaceReadiness{
+				MinAge: &metav1.Duration{Duration: time.Minute},
+				Label:  "ready",
+			},
+			age: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var co = &clusterv1alpha1.ClusterObject{}
+			co.Replicator.NamespaceReadiness = tt.readiness
+
+			var namespace = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:              "app",
+				Labels:            tt.labels,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-tt.age)),
+			}}
+
+			ready, after := namespaceReadiness(co, namespace)
+			if ready != tt.ready {
+				t.Errorf("expected ready %v, got %v", tt.ready, ready)
+			}
+			if after < tt.after-time.Second || after > tt.after+time.Second {
+				t.Errorf("expected the namespace to be ready after %s, got %s", tt.after, after)
+			}
+		})
+	}
+}
+
+func TestReconcileWaitsForNamespaceReadiness(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("readiness", map[string]any{"key": "value"})
+	co.Replicator.NamespaceReadiness = &clusterv1alpha1.ClusterObjectNamespaceReadiness{
+		MinAge: &metav1.Duration{Duration: time.Hour},
+	}
+
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "young", CreationTimestamp: metav1.Now()}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "terminating"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating}})
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: co.GetName()}})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	// the reconciliation is requeued, as soon as the namespace reaches its minimum age
+	if result.RequeueAfter < 59*time.Minute || result.RequeueAfter > time.Hour {
+		t.Errorf("expected a requeue after the minimum age, got %s", result.RequeueAfter)
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+
+	// terminating namespaces are neither targets nor failures
+	if len(co.Status.Targets) != 1 || co.Status.Targets[0].Namespace != "young" ||
+		co.Status.Targets[0].State != clusterv1alpha1.TargetStatePending {
+		t.Fatalf("expected a single pending target, got %+v", co.Status.Targets)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "young", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no object in the young namespace, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// apply all overlays of the clusterobject, which select the given namespace and resource,
+// in order on top of the object
+func (r *ClusterObjectReconciler) applyOverlays(
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured,
+	namespace corev1.Namespace) (*unstructured.Unstructured, error) {
+
+	for i, overlay := range co.Replicator.Overlays {
+
+		matches, err := overlayMatches(overlay, typedObject, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("overlay %d: %w", i, err)
+		}
+		if !matches {
+			continue
+		}
+
+		if typedObject, err = r.applyOverlay(overlay, typedObject); err != nil {
+			return nil, fmt.Errorf("overlay %d: %w", i, err)
+		}
+	}
+
+	return typedObject, nil
+}
+
+// validate wether an overlay applies to an object in a given namespace
+func overlayMatches(
+	overlay clusterv1alpha1.ClusterObjectOverlay,
+	typedObject *unstructured.Unstructured,
+	namespace corev1.Namespace) (bool, error) {
+
+	if target := overlay.Target; target != nil {
+		if target.Kind != "" && target.Kind != typedObject.GetKind() {
+			return false, nil
+		}
+		if target.Name != "" && target.Name != typedObject.GetName() {
+			return false, nil
+		}
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&overlay.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+
+	return selector.Matches(labels.Set(namespace.GetLabels())), nil
+}
+
+// apply the patch of a single overlay to an object
+func (r *ClusterObjectReconciler) applyOverlay(
+	overlay clusterv1alpha1.ClusterObjectOverlay,
+	typedObject *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+
+	original, err := typedObject.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := yaml.YAMLToJSON([]byte(overlay.Patch))
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	var patched []byte
+	switch overlay.Type {
+
+	case clusterv1alpha1.OverlayTypeJSON6902:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid patch: %w", err)
+		}
+		if patched, err = operations.Apply(original); err != nil {
+			return nil, err
+		}
+
+	default:
+		// the strategic merge patch requires the go type of the kind, kinds which are not
+		// registered in the scheme are patched with a json merge patch
+		if dataStruct, err := r.Scheme.New(typedObject.GroupVersionKind()); err == nil {
+			patched, err = strategicpatch.StrategicMergePatch(original, patch, dataStruct)
+			if err != nil {
+				return nil, err
+			}
+		} else if patched, err = jsonpatch.MergePatch(original, patch); err != nil {
+			return nil, err
+		}
+	}
+
+	var patchedObject = &unstructured.Unstructured{}
+	if err := patchedObject.UnmarshalJSON(patched); err != nil {
+		return nil, err
+	}
+
+	// the identity of the object can not be changed by an overlay, otherwise
+	// the object could not be found in the namespace
+	if patchedObject.GroupVersionKind() != typedObject.GroupVersionKind() ||
+		patchedObject.GetName() != typedObject.GetName() {
+		return nil, fmt.Errorf("the patch must not change the apiVersion, kind or name of the resource")
+	}
+
+	return patchedObject, nil
+}
@@ -0,0 +1,220 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create a deployment with a single container for the overlay tests
+func newOverlayDeployment() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "app"},
+		"spec": map[string]any{
+			"replicas": int64(1),
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "app", "image": "app:1"},
+				map[string]any{"name": "sidecar", "image": "sidecar:1"},
+			}}},
+		},
+	}}
+}
+
+func TestApplyOverlays(t *testing.T) {
+
+	var prod = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}}
+	var selectProd = metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+
+	var tests = []struct {
+		name      string
+		resource  *unstructured.Unstructured
+		overlays  []clusterv1alpha1.ClusterObjectOverlay
+		namespace corev1.Namespace
+		field     []string
+		expected  any
+		invalid   string
+	}{
+		{
+			name:     "strategic merge patch merges the containers by name",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				NamespaceSelector: selectProd,
+				Patch:             "spec:\n  template:\n    spec:\n      containers:\n      - name: app\n        image: app:2\n",
+			}},
+			namespace: prod,
+			field:     []string{"spec", "template", "spec", "containers"},
+			expected: []any{
+				map[string]any{"name": "app", "image": "app:2"},
+				map[string]any{"name": "sidecar", "image": "sidecar:1"},
+			},
+		},
+		{
+			name:     "json 6902 patch",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				NamespaceSelector: selectProd,
+				Type:              clusterv1alpha1.OverlayTypeJSON6902,
+				Patch:             `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+			}},
+			namespace: prod,
+			field:     []string{"spec", "replicas"},
+			expected:  int64(3),
+		},
+		{
+			name: "unknown kinds are patched with a json merge patch",
+			resource: &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "example.com/v1",
+				"kind":       "Widget",
+				"metadata":   map[string]any{"name": "widget"},
+				"spec":       map[string]any{"items": []any{"a", "b"}, "size": "small"},
+			}},
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				NamespaceSelector: selectProd,
+				Patch:             `{"spec": {"items": ["c"]}}`,
+			}},
+			namespace: prod,
+			field:     []string{"spec"},
+			expected:  map[string]any{"items": []any{"c"}, "size": "small"},
+		},
+		{
+			name:     "overlays are applied in order",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{
+				{Patch: "spec:\n  replicas: 2\n"},
+				{NamespaceSelector: selectProd, Patch: "spec:\n  replicas: 5\n"},
+			},
+			namespace: prod,
+			field:     []string{"spec", "replicas"},
+			expected:  int64(5),
+		},
+		{
+			name:     "namespace is not selected",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				NamespaceSelector: selectProd,
+				Patch:             "spec:\n  replicas: 5\n",
+			}},
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev", Labels: map[string]string{"env": "dev"}}},
+			field:     []string{"spec", "replicas"},
+			expected:  int64(1),
+		},
+		{
+			name:     "target kind does not match",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				Target: &clusterv1alpha1.ClusterObjectOverlayTarget{Kind: "StatefulSet"},
+				Patch:  "spec:\n  replicas: 5\n",
+			}},
+			namespace: prod,
+			field:     []string{"spec", "replicas"},
+			expected:  int64(1),
+		},
+		{
+			name:     "target name matches",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				Target: &clusterv1alpha1.ClusterObjectOverlayTarget{Kind: "Deployment", Name: "app"},
+				Patch:  "spec:\n  replicas: 5\n",
+			}},
+			namespace: prod,
+			field:     []string{"spec", "replicas"},
+			expected:  int64(5),
+		},
+		{
+			name:     "identity must not change",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				Patch: "metadata:\n  name: other\n",
+			}},
+			namespace: prod,
+			invalid:   "must not change",
+		},
+		{
+			name:     "invalid patch",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				Type:  clusterv1alpha1.OverlayTypeJSON6902,
+				Patch: `{"op": "replace"}`,
+			}},
+			namespace: prod,
+			invalid:   "overlay 0: invalid patch",
+		},
+		{
+			name:     "failing operation names the overlay",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{
+				{Patch: "spec:\n  replicas: 2\n"},
+				{Type: clusterv1alpha1.OverlayTypeJSON6902, Patch: `[{"op": "remove", "path": "/spec/missing"}]`},
+			},
+			namespace: prod,
+			invalid:   "overlay 1",
+		},
+		{
+			name:     "invalid namespace selector",
+			resource: newOverlayDeployment(),
+			overlays: []clusterv1alpha1.ClusterObjectOverlay{{
+				NamespaceSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Unknown"}}},
+				Patch:             "spec:\n  replicas: 5\n",
+			}},
+			namespace: prod,
+			invalid:   "overlay 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newFakeReconciler(t)
+
+			var co = &clusterv1alpha1.ClusterObject{}
+			co.Replicator.Overlays = tt.overlays
+
+			patched, err := r.applyOverlays(co, tt.resource, tt.namespace)
+			if tt.invalid != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.invalid) {
+					t.Fatalf("expected an error containing %q, got %v", tt.invalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			value, _, _ := unstructured.NestedFieldNoCopy(patched.Object, tt.field...)
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
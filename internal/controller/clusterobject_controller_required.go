@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// the name of the field index of the clusterobjects, which contains the kinds of the required objects
+const requiredIndexField = "replicator.requireObjects"
+
+// create the key of a required kind for the field index of the clusterobjects
+func requiredKey(apiVersion, kind string) string {
+	return fmt.Sprintf("%s/%s", apiVersion, kind)
+}
+
+// index the clusterobjects by the kinds of their required objects
+func indexRequiredObjects(obj client.Object) []string {
+
+	clusterObject, ok := obj.(*clusterv1alpha1.ClusterObject)
+	if !ok {
+		return nil
+	}
+
+	var keys []string
+	for _, required := range clusterObject.Replicator.RequireObjects {
+		keys = append(keys, requiredKey(required.APIVersion, required.Kind))
+	}
+
+	return keys
+}
+
+// validate wether all required objects of the clusterobject exist in the namespace. only the
+// metadata of the objects is read, so the cache does not have to keep the full objects.
+func (r *ClusterObjectReconciler) requiredObjectsExist(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) (bool, error) {
+
+	for _, required := range co.Replicator.RequireObjects {
+
+		var listOpts = []client.ListOption{client.InNamespace(namespace.GetName())}
+		if required.LabelSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(required.LabelSelector)
+			if err != nil {
+				return false, err
+			}
+			listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: selector})
+		}
+
+		var list = &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(schema.FromAPIVersionAndKind(required.APIVersion, required.Kind+"List"))
+		if err := r.List(ctx, list, listOpts...); err != nil {
+			return false, fmt.Errorf("required objects [%s/%s]: %w", required.APIVersion, required.Kind, err)
+		}
+
+		var found bool
+		for _, item := range list.Items {
+			if required.Name == "" || item.GetName() == required.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,157 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create a secret, which is required by the clusterobjects
+func newRequiredSecret(namespace, name string, labels map[string]string) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+}
+
+func TestRequiredObjectsExist(t *testing.T) {
+
+	var tests = []struct {
+		name     string
+		required []clusterv1alpha1.ClusterObjectRequiredObject
+		exists   bool
+	}{
+		{
+			name:     "any object of the kind",
+			required: []clusterv1alpha1.ClusterObjectRequiredObject{{APIVersion: "v1", Kind: "Secret"}},
+			exists:   true,
+		},
+		{
+			name:     "object by name",
+			required: []clusterv1alpha1.ClusterObjectRequiredObject{{APIVersion: "v1", Kind: "Secret", Name: "pull-secret"}},
+			exists:   true,
+		},
+		{
+			name:     "missing object by name",
+			required: []clusterv1alpha1.ClusterObjectRequiredObject{{APIVersion: "v1", Kind: "Secret", Name: "tls"}},
+		},
+		{
+			name: "object by label",
+			required: []clusterv1alpha1.ClusterObjectRequiredObject{{
+				APIVersion:    "v1",
+				Kind:          "Secret",
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"type": "registry"}},
+			}},
+			exists: true,
+		},
+		{
+			name: "object by name without the label",
+			required: []clusterv1alpha1.ClusterObjectRequiredObject{{
+				APIVersion:    "v1",
+				Kind:          "Secret",
+				Name:          "pull-secret",
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"type": "tls"}},
+			}},
+		},
+		{
+			name: "one of several objects missing",
+			required: []clusterv1alpha1.ClusterObjectRequiredObject{
+				{APIVersion: "v1", Kind: "Secret", Name: "pull-secret"},
+				{APIVersion: "v1", Kind: "ConfigMap"},
+			},
+		},
+	}
+
+	r, _ := newFakeReconciler(t,
+		newRequiredSecret("app", "pull-secret", map[string]string{"type": "registry"}),
+		newRequiredSecret("other", "tls", map[string]string{"type": "tls"}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var co = &clusterv1alpha1.ClusterObject{}
+			co.Replicator.RequireObjects = tt.required
+
+			exists, err := r.requiredObjectsExist(context.Background(), co,
+				corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if exists != tt.exists {
+				t.Errorf("expected %v, got %v", tt.exists, exists)
+			}
+		})
+	}
+}
+
+func TestIndexRequiredObjects(t *testing.T) {
+
+	var co = &clusterv1alpha1.ClusterObject{}
+	co.Replicator.RequireObjects = []clusterv1alpha1.ClusterObjectRequiredObject{
+		{APIVersion: "v1", Kind: "Secret"},
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "app"},
+	}
+
+	var keys = indexRequiredObjects(co)
+	if len(keys) != 2 || keys[0] != "v1/Secret" || keys[1] != "apps/v1/Deployment" {
+		t.Errorf("expected the keys of both kinds, got %v", keys)
+	}
+}
+
+func TestReconcileRequiresObjects(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("required", map[string]any{"key": "value"})
+	co.Replicator.RequireObjects = []clusterv1alpha1.ClusterObjectRequiredObject{
+		{APIVersion: "v1", Kind: "Secret", Name: "pull-secret"},
+	}
+
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+		newRequiredSecret("app", "pull-secret", nil))
+
+	var targets = reconcileTargets(t, r, co.GetName())
+	if len(targets) != 1 || targets[0].Namespace != "app" {
+		t.Fatalf("expected only the namespace with the required object as target, got %+v", targets)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "other", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected no object in the namespace without the required object, got %v", err)
+	}
+
+	// the object is removed, once the required object is gone
+	if err := c.Delete(ctx, newRequiredSecret("app", "pull-secret", nil)); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTargets(t, r, co.GetName())
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the object to be pruned without the required object, got %v", err)
+	}
+}
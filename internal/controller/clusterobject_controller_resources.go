@@ -0,0 +1,317 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// SyncWaveAnnotation is the annotation, which defines the sync wave of a replicated object.
+// objects with a lower wave are applied first and deleted last. defaults to 0.
+const SyncWaveAnnotation = "cluster.jnnkrdb.de/sync-wave"
+
+// the order, in which the kinds of a wave are applied. the order follows the install order
+// of helm, so objects are applied before the objects, which depend on them. kinds, which
+// are not listed, are applied last.
+var kindOrder = map[string]int{}
+
+func init() {
+	for i, kind := range []string{
+		"NetworkPolicy",
+		"ResourceQuota",
+		"LimitRange",
+		"PodDisruptionBudget",
+		"ServiceAccount",
+		"Secret",
+		"ConfigMap",
+		"PersistentVolumeClaim",
+		"Role",
+		"RoleBinding",
+		"Service",
+		"DaemonSet",
+		"Pod",
+		"ReplicationController",
+		"ReplicaSet",
+		"Deployment",
+		"HorizontalPodAutoscaler",
+		"StatefulSet",
+		"Job",
+		"CronJob",
+		"Ingress",
+	} {
+		kindOrder[kind] = i
+	}
+}
+
+// calculate the list of objects, which should be replicated by the clusterobject. the
+// objects are sorted in the order, in which they are applied.
+func (r *ClusterObjectReconciler) desiredResources(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject) ([]*unstructured.Unstructured, error) {
+
+	var resources []*unstructured.Unstructured
+	if len(co.Replicator.Resource.Object) > 0 {
+		resources = append(resources, co.Replicator.Resource.DeepCopy())
+	}
+	for i := range co.Replicator.Resources {
+		resources = append(resources, co.Replicator.Resources[i].DeepCopy())
+	}
+	if co.Replicator.Source != nil {
+		source, err := r.sourceResource(ctx, co)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, source)
+	}
+
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("the clusterobject does not contain any resource")
+	}
+
+	var waves = make(map[*unstructured.Unstructured]int, len(resources))
+	var refs = make(map[inventoryKey]struct{}, len(resources))
+	for _, resource := range resources {
+		var ref = resourceRef(resource)
+		if ref.APIVersion == "" || ref.Kind == "" || ref.Name == "" {
+			return nil, fmt.Errorf("resource [%s/%s:%s] requires an apiVersion, a kind and a name", ref.APIVersion, ref.Kind, ref.Name)
+		}
+		// the versions of a kind serve the same objects
+		var key = newInventoryKey(ref.APIVersion, ref.Kind, ref.Name)
+		if _, ok := refs[key]; ok {
+			return nil, fmt.Errorf("resource [%s/%s:%s] is defined more than once", ref.APIVersion, ref.Kind, ref.Name)
+		}
+		refs[key] = struct{}{}
+
+		wave, err := syncWave(resource)
+		if err != nil {
+			return nil, err
+		}
+		waves[resource] = wave
+	}
+
+	// every generator requires a secret, whose keys are generated
+	for _, generator := range co.Replicator.Generators {
+		if _, ok := refs[newInventoryKey("v1", "Secret", generator.SecretName)]; !ok {
+			return nil, fmt.Errorf("generator references the secret %q, which is not part of the resources", generator.SecretName)
+		}
+	}
+
+	if err := validateIgnoreDifferences(co); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool {
+		if wi, wj := waves[resources[i]], waves[resources[j]]; wi != wj {
+			return wi < wj
+		}
+		return kindPriority(resources[i].GetKind()) < kindPriority(resources[j].GetKind())
+	})
+
+	return resources, nil
+}
+
+// calculate the object, which should exist in the given namespace, from a resource of the
+// clusterobject. the resource itself is not changed.
+func (r *ClusterObjectReconciler) desiredObject(
+	co *clusterv1alpha1.ClusterObject,
+	resource *unstructured.Unstructured,
+	namespace corev1.Namespace) (*unstructured.Unstructured, error) {
+
+	var typedObject = resource.DeepCopy()
+
+	// add the common labels and annotations of the clusterobject
+	setCommonMetadata(co, typedObject)
+
+	// patch the resource with the overlays, which select the namespace
+	typedObject, err := r.applyOverlays(co, typedObject, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// render the resource for the namespace
+	if co.Replicator.Template {
+		if typedObject, err = renderTemplate(typedObject, namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	// change the namespace, to the requested namespace
+	typedObject.SetNamespace(namespace.GetName())
+
+	return typedObject, nil
+}
+
+// calculate the number of objects, which should exist in the target namespaces
+func desiredTargets(
+	co *clusterv1alpha1.ClusterObject,
+	requiredNamespaces *corev1.NamespaceList,
+	resources []*unstructured.Unstructured) int32 {
+
+	var desired int32
+	for _, namespace := range requiredNamespaces.Items {
+		for _, resource := range resources {
+			if !isSource(co, namespace, resource) {
+				desired++
+			}
+		}
+	}
+
+	return desired
+}
+
+// read the sync wave of an object from its annotations
+func syncWave(typedObject *unstructured.Unstructured) (int, error) {
+
+	value, ok := typedObject.GetAnnotations()[SyncWaveAnnotation]
+	if !ok {
+		return 0, nil
+	}
+
+	wave, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sync wave %q of resource [%s:%s]: %w", value, typedObject.GetKind(), typedObject.GetName(), err)
+	}
+
+	return wave, nil
+}
+
+// return the position of a kind in the apply order
+func kindPriority(kind string) int {
+	if priority, ok := kindOrder[kind]; ok {
+		return priority
+	}
+	return len(kindOrder)
+}
+
+// create the reference of a replicated object for the inventory
+func resourceRef(typedObject *unstructured.Unstructured) clusterv1alpha1.ClusterObjectResourceRef {
+	return clusterv1alpha1.ClusterObjectResourceRef{
+		APIVersion: typedObject.GetAPIVersion(),
+		Kind:       typedObject.GetKind(),
+		Name:       typedObject.GetName(),
+	}
+}
+
+// the key of an object in the inventory. the version is not part of the key, since
+// all versions of a kind serve the same objects.
+type inventoryKey struct {
+	group string
+	kind  string
+	name  string
+}
+
+// create the key of an object in the inventory
+func newInventoryKey(apiVersion, kind, name string) inventoryKey {
+	return inventoryKey{
+		group: schema.FromAPIVersionAndKind(apiVersion, kind).Group,
+		kind:  kind,
+		name:  name,
+	}
+}
+
+// calculate the objects of the inventory, which are no longer part of the desired
+// resources. these objects are pruned from all namespaces, in the reverse order of
+// the inventory. objects, whose resource only changed its version, are not stale.
+func staleResources(
+	co *clusterv1alpha1.ClusterObject,
+	resources []*unstructured.Unstructured) []*unstructured.Unstructured {
+
+	var desired = make(map[inventoryKey]struct{}, len(resources))
+	for _, resource := range resources {
+		desired[newInventoryKey(resource.GetAPIVersion(), resource.GetKind(), resource.GetName())] = struct{}{}
+	}
+
+	var stale []*unstructured.Unstructured
+	for i := len(co.Status.Inventory) - 1; i >= 0; i-- {
+		var ref = co.Status.Inventory[i]
+		if _, ok := desired[newInventoryKey(ref.APIVersion, ref.Kind, ref.Name)]; ok {
+			continue
+		}
+
+		var typedObject = &unstructured.Unstructured{}
+		typedObject.SetAPIVersion(ref.APIVersion)
+		typedObject.SetKind(ref.Kind)
+		typedObject.SetName(ref.Name)
+		stale = append(stale, typedObject)
+	}
+
+	return stale
+}
+
+// calculate the inventory of the clusterobject. stale objects are only removed from the
+// inventory, after they were pruned from all namespaces. as long as any namespace did not
+// finish its reconciliation, the stale objects are kept, so they are pruned with the
+// next reconciliation.
+func setInventory(
+	co *clusterv1alpha1.ClusterObject,
+	resources []*unstructured.Unstructured,
+	stale []*unstructured.Unstructured,
+	pruned bool) {
+
+	var inventory = make([]clusterv1alpha1.ClusterObjectResourceRef, 0, len(resources)+len(stale))
+	for _, resource := range resources {
+		inventory = append(inventory, resourceRef(resource))
+	}
+
+	if !pruned {
+		// the stale objects are in reverse order, so they are appended from the back
+		for i := len(stale) - 1; i >= 0; i-- {
+			inventory = append(inventory, resourceRef(stale[i]))
+		}
+	}
+
+	co.Status.Inventory = inventory
+}
+
+// reverse the order of a list of objects, without changing the given list
+func reverseResources(resources []*unstructured.Unstructured) []*unstructured.Unstructured {
+
+	var reversed = make([]*unstructured.Unstructured, 0, len(resources))
+	for i := len(resources) - 1; i >= 0; i-- {
+		reversed = append(reversed, resources[i])
+	}
+
+	return reversed
+}
+
+// describe the replicated objects for messages
+func describeResources(resources []*unstructured.Unstructured) string {
+
+	var names = make([]string, 0, len(resources))
+	for _, resource := range resources {
+		names = append(names, fmt.Sprintf("[%s/%s:%s]", resource.GetAPIVersion(), resource.GetKind(), resource.GetName()))
+	}
+
+	return summarizeNames(names)
+}
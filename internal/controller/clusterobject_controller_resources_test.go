@@ -0,0 +1,170 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create a resource with the given api version, kind and name
+func newResource(apiVersion, kind, name string) *unstructured.Unstructured {
+	var resource = &unstructured.Unstructured{}
+	resource.SetAPIVersion(apiVersion)
+	resource.SetKind(kind)
+	resource.SetName(name)
+	return resource
+}
+
+func TestStaleResources(t *testing.T) {
+
+	var tests = []struct {
+		name      string
+		inventory []clusterv1alpha1.ClusterObjectResourceRef
+		resources []*unstructured.Unstructured
+		expected  []clusterv1alpha1.ClusterObjectResourceRef
+	}{
+		{
+			name:      "empty inventory",
+			resources: []*unstructured.Unstructured{newResource("v1", "ConfigMap", "a")},
+		},
+		{
+			name: "unchanged resources",
+			inventory: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "a"},
+			},
+			resources: []*unstructured.Unstructured{newResource("v1", "ConfigMap", "a")},
+		},
+		{
+			name: "removed resources in reverse order",
+			inventory: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "a"},
+				{APIVersion: "v1", Kind: "Secret", Name: "b"},
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: "c"},
+			},
+			resources: []*unstructured.Unstructured{newResource("v1", "Secret", "b")},
+			expected: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: "c"},
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "a"},
+			},
+		},
+		{
+			name: "changed version",
+			inventory: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", Name: "a"},
+			},
+			resources: []*unstructured.Unstructured{newResource("autoscaling/v2", "HorizontalPodAutoscaler", "a")},
+		},
+		{
+			name: "changed group",
+			inventory: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "extensions/v1beta1", Kind: "Ingress", Name: "a"},
+			},
+			resources: []*unstructured.Unstructured{newResource("networking.k8s.io/v1", "Ingress", "a")},
+			expected: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "extensions/v1beta1", Kind: "Ingress", Name: "a"},
+			},
+		},
+		{
+			name: "changed name",
+			inventory: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "a"},
+			},
+			resources: []*unstructured.Unstructured{newResource("v1", "ConfigMap", "b")},
+			expected: []clusterv1alpha1.ClusterObjectResourceRef{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var co = &clusterv1alpha1.ClusterObject{}
+			co.Status.Inventory = tt.inventory
+
+			var stale = staleResources(co, tt.resources)
+			if len(stale) != len(tt.expected) {
+				t.Fatalf("expected %d stale resources, got %d", len(tt.expected), len(stale))
+			}
+			for i := range stale {
+				if ref := resourceRef(stale[i]); ref != tt.expected[i] {
+					t.Errorf("expected stale resource %d to be %+v, got %+v", i, tt.expected[i], ref)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncWave(t *testing.T) {
+
+	var tests = []struct {
+		name        string
+		annotations map[string]string
+		expected    int
+		expectErr   bool
+	}{
+		{name: "no annotation", expected: 0},
+		{name: "positive wave", annotations: map[string]string{SyncWaveAnnotation: "2"}, expected: 2},
+		{name: "negative wave", annotations: map[string]string{SyncWaveAnnotation: "-1"}, expected: -1},
+		{name: "invalid wave", annotations: map[string]string{SyncWaveAnnotation: "first"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resource = newResource("v1", "ConfigMap", "a")
+			resource.SetAnnotations(tt.annotations)
+
+			wave, err := syncWave(resource)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if wave != tt.expected {
+				t.Errorf("expected wave %d, got %d", tt.expected, wave)
+			}
+		})
+	}
+}
+
+func TestKindPriority(t *testing.T) {
+
+	// the kinds are applied in the install order of helm
+	var ordered = []string{"NetworkPolicy", "ServiceAccount", "Secret", "ConfigMap", "Role", "RoleBinding", "Service", "Deployment", "Ingress"}
+	for i := 1; i < len(ordered); i++ {
+		if kindPriority(ordered[i-1]) >= kindPriority(ordered[i]) {
+			t.Errorf("expected %s to be applied before %s", ordered[i-1], ordered[i])
+		}
+	}
+
+	// unknown kinds are applied last
+	if kindPriority("Unknown") <= kindPriority("Ingress") {
+		t.Errorf("expected unknown kinds to be applied after all known kinds")
+	}
+	if kindPriority("Unknown") != kindPriority("Other") {
+		t.Errorf("expected unknown kinds to share the same priority")
+	}
+}
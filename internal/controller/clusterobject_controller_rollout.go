@@ -0,0 +1,428 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+const (
+	// the default number of namespaces per step of a rollout
+	defaultRolloutBatchSize = "25%"
+
+	// the interval, in which the health of a step is checked, while the objects are not
+	// healthy. changes of the replicated objects trigger the check immediately.
+	rolloutHealthInterval = 30 * time.Second
+)
+
+// calculate the revision of the rollout. the revision covers the resources and all fields
+// of the replicator, which change the content of the replicated objects. changes of the
+// namespace selection do not start a new rollout.
+func rolloutRevision(
+	co *clusterv1alpha1.ClusterObject,
+	resources []*unstructured.Unstructured) (string, error) {
+
+	content, err := json.Marshal(struct {
+		Resources         []*unstructured.Unstructured             `json:"resources"`
+		Generators        []clusterv1alpha1.ClusterObjectGenerator `json:"generators,omitempty"`
+		CommonLabels      map[string]string                        `json:"commonLabels,omitempty"`
+		CommonAnnotations map[string]string                        `json:"commonAnnotations,omitempty"`
+		Template          bool                                     `json:"template,omitempty"`
+		Overlays          []clusterv1alpha1.ClusterObjectOverlay   `json:"overlays,omitempty"`
+		DataMergeStrategy clusterv1alpha1.DataMergeStrategy        `json:"dataMergeStrategy,omitempty"`
+	}{
+		Resources:         resources,
+		Generators:        co.Replicator.Generators,
+		CommonLabels:      co.Replicator.CommonLabels,
+		CommonAnnotations: co.Replicator.CommonAnnotations,
+		Template:          co.Replicator.Template,
+		Overlays:          co.Replicator.Overlays,
+		DataMergeStrategy: co.Replicator.DataMergeStrategy,
+	})
+	if err != nil {
+		return "", err
+	}
+	var hash = sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:8]), nil
+}
+
+// calculate the steps of a rollout. the canaries are the first step, the other target
+// namespaces follow in batches. the namespaces are sorted by their names, so the steps
+// do not change between the reconciliations.
+func rolloutSteps(
+	rollout *clusterv1alpha1.ClusterObjectRollout,
+	requiredNamespaces *corev1.NamespaceList) ([][]string, error) {
+
+	var canaries, remaining []string
+	for _, namespace := range requiredNamespaces.Items {
+		canary, err := isCanary(rollout.Canary, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if canary {
+			canaries = append(canaries, namespace.GetName())
+		} else {
+			remaining = append(remaining, namespace.GetName())
+		}
+	}
+	slices.Sort(canaries)
+	slices.Sort(remaining)
+
+	var steps [][]string
+	if len(canaries) > 0 {
+		steps = append(steps, canaries)
+	}
+
+	var batchSize = intstr.FromString(defaultRolloutBatchSize)
+	if rollout.BatchSize != nil {
+		batchSize = *rollout.BatchSize
+	}
+	size, err := intstr.GetScaledValueFromIntOrPercent(&batchSize, len(remaining), true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batchSize: %w", err)
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	for batch := range slices.Chunk(remaining, size) {
+		steps = append(steps, batch)
+	}
+
+	return steps, nil
+}
+
+// validate wether a namespace is a canary of the rollout
+func isCanary(
+	canary *clusterv1alpha1.ClusterObjectRolloutCanary,
+	namespace corev1.Namespace) (bool, error) {
+
+	if canary == nil {
+		return false, nil
+	}
+
+	if slices.Contains(canary.Names, namespace.GetName()) {
+		return true, nil
+	}
+
+	if canary.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(canary.Selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid canary selector: %w", err)
+	}
+
+	return selector.Matches(labels.Set(namespace.GetLabels())), nil
+}
+
+// start a new rollout, if the revision of the resources changed, and calculate the namespaces,
+// which are part of the current or a finished step. nil is returned, if all namespaces can be
+// updated, because the rollout is completed.
+func startRollout(
+	co *clusterv1alpha1.ClusterObject,
+	revision string,
+	steps [][]string) map[string]struct{} {
+
+	// the dry-run does not change any namespace, so there is nothing to roll out
+	if co.Replicator.DryRun {
+		return nil
+	}
+
+	var status = co.Status.Rollout
+	if status == nil || status.Revision != revision {
+		var now = metav1.Now()
+		status = &clusterv1alpha1.ClusterObjectRolloutStatus{
+			Revision:      revision,
+			Phase:         clusterv1alpha1.RolloutPhaseProgressing,
+			StepStartTime: &now,
+		}
+		co.Status.Rollout = status
+	}
+
+	// namespaces may disappear during a rollout, so the steps may shrink
+	status.Steps = int32(len(steps))
+	if status.Step >= status.Steps && status.Steps > 0 {
+		status.Step = status.Steps - 1
+	}
+
+	status.Namespaces = 0
+	for _, step := range steps {
+		status.Namespaces += int32(len(step))
+	}
+
+	if status.Phase == clusterv1alpha1.RolloutPhaseCompleted {
+		status.UpdatedNamespaces = status.Namespaces
+		return nil
+	}
+
+	var updated = map[string]struct{}{}
+	for _, step := range steps[:min(int(status.Step)+1, len(steps))] {
+		for _, namespace := range step {
+			updated[namespace] = struct{}{}
+		}
+	}
+	status.UpdatedNamespaces = int32(len(updated))
+
+	return updated
+}
+
+// validate wether a namespace waits for its step of the rollout
+func waitsForRollout(
+	updated map[string]struct{},
+	namespace corev1.Namespace) bool {
+
+	if updated == nil {
+		return false
+	}
+
+	_, ok := updated[namespace.GetName()]
+	return !ok
+}
+
+// create the target of an object, which waits for its step of the rollout
+func waitingTarget(
+	namespace corev1.Namespace,
+	typedObject *unstructured.Unstructured) clusterv1alpha1.ClusterObjectTarget {
+
+	return clusterv1alpha1.ClusterObjectTarget{
+		Namespace:  namespace.GetName(),
+		APIVersion: typedObject.GetAPIVersion(),
+		Kind:       typedObject.GetKind(),
+		Name:       typedObject.GetName(),
+		State:      clusterv1alpha1.TargetStateWaiting,
+	}
+}
+
+// progress the rollout after the namespaces of the current step were reconciled. the rollout
+// halts, if too many objects failed. otherwise the next step starts, after the pause passed
+// and the objects of the current step are healthy. the returned duration is the time, after
+// which the rollout should be checked again.
+func (r *ClusterObjectReconciler) progressRollout(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	steps [][]string,
+	resources []*unstructured.Unstructured,
+	targets []clusterv1alpha1.ClusterObjectTarget) (time.Duration, error) {
+
+	var status = co.Status.Rollout
+	if status == nil || co.Replicator.DryRun || status.Phase != clusterv1alpha1.RolloutPhaseProgressing {
+		return 0, nil
+	}
+
+	var _log = log.FromContext(ctx).WithValues("revision", status.Revision, "step", status.Step)
+
+	// the failed objects of the updated namespaces decide, wether the rollout continues
+	status.Failures = 0
+	for _, target := range targets {
+		if target.State == clusterv1alpha1.TargetStateFailed ||
+			target.State == clusterv1alpha1.TargetStateQuarantined {
+			status.Failures++
+		}
+	}
+
+	var maxFailures = intstr.FromInt32(0)
+	if co.Replicator.Rollout.MaxFailures != nil {
+		maxFailures = *co.Replicator.Rollout.MaxFailures
+	}
+	tolerated, err := intstr.GetScaledValueFromIntOrPercent(&maxFailures, int(co.Status.Summary.Desired), false)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maxFailures: %w", err)
+	}
+
+	if int(status.Failures) > tolerated {
+		status.Phase = clusterv1alpha1.RolloutPhaseHalted
+		status.Message = fmt.Sprintf("halted at step %d/%d, %d object(s) failed, %d tolerated",
+			status.Step+1, status.Steps, status.Failures, tolerated)
+
+		_log.Info("rollout halted", "failures", status.Failures)
+		r.Recorder.Eventf(co, "Warning", "RolloutHalted", "rollout of revision %s %s", status.Revision, status.Message)
+
+		return 0, nil
+	}
+
+	if status.Steps == 0 {
+		r.completeRollout(ctx, co)
+		return 0, nil
+	}
+
+	// the objects of the current step must be healthy, before the next step starts
+	if healthy, reason, err := r.stepHealthy(ctx, co, steps[status.Step], resources, targets); err != nil {
+		return 0, err
+	} else if !healthy {
+		status.Message = fmt.Sprintf("step %d/%d is waiting for %s", status.Step+1, status.Steps, reason)
+		return rolloutHealthInterval, nil
+	}
+
+	if co.Replicator.Rollout.Pause != nil && status.StepStartTime != nil {
+		if remaining := time.Until(status.StepStartTime.Add(co.Replicator.Rollout.Pause.Duration)); remaining > 0 {
+			status.Message = fmt.Sprintf("step %d/%d is healthy, pausing until %s",
+				status.Step+1, status.Steps, status.StepStartTime.Add(co.Replicator.Rollout.Pause.Duration).Format(time.RFC3339))
+			return remaining, nil
+		}
+	}
+
+	if status.Step+1 >= status.Steps {
+		r.completeRollout(ctx, co)
+		return 0, nil
+	}
+
+	// the next step is reconciled with the next reconciliation, which is triggered
+	// by the update of the status
+	var now = metav1.Now()
+	status.Step++
+	status.StepStartTime = &now
+	status.UpdatedNamespaces += int32(len(steps[status.Step]))
+	status.Message = fmt.Sprintf("started step %d/%d", status.Step+1, status.Steps)
+
+	_log.Info("rollout step started", "namespaces", steps[status.Step])
+	r.Recorder.Eventf(co, "Normal", "RolloutStep", "rollout of revision %s started step %d/%d with %d namespace(s)",
+		status.Revision, status.Step+1, status.Steps, len(steps[status.Step]))
+
+	return 0, nil
+}
+
+// mark the rollout as completed, all target namespaces are updated
+func (r *ClusterObjectReconciler) completeRollout(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject) {
+
+	var status = co.Status.Rollout
+	status.Phase = clusterv1alpha1.RolloutPhaseCompleted
+	status.UpdatedNamespaces = status.Namespaces
+	status.Message = ""
+
+	log.FromContext(ctx).Info("rollout completed", "revision", status.Revision)
+	r.Recorder.Eventf(co, "Normal", "RolloutCompleted", "rollout of revision %s completed in %d namespace(s)",
+		status.Revision, status.Namespaces)
+}
+
+// validate wether the objects in the namespaces of a step are healthy. the objects must be
+// synced and must report their readiness, if they have a status. failed objects are counted
+// as failures of the rollout, they do not block the step. the returned reason describes the
+// first object, which is not healthy.
+func (r *ClusterObjectReconciler) stepHealthy(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	step []string,
+	resources []*unstructured.Unstructured,
+	targets []clusterv1alpha1.ClusterObjectTarget) (bool, string, error) {
+
+	var states = map[string]clusterv1alpha1.TargetState{}
+	for _, target := range targets {
+		states[describeTarget(target)] = target.State
+	}
+
+	for _, namespace := range step {
+		for _, resource := range resources {
+
+			var target = clusterv1alpha1.ClusterObjectTarget{
+				Namespace:  namespace,
+				APIVersion: resource.GetAPIVersion(),
+				Kind:       resource.GetKind(),
+				Name:       resource.GetName(),
+			}
+
+			state, ok := states[describeTarget(target)]
+			if !ok {
+				// the source is never replicated into its own namespace
+				continue
+			}
+
+			switch state {
+			case clusterv1alpha1.TargetStateFailed,
+				clusterv1alpha1.TargetStateQuarantined,
+				clusterv1alpha1.TargetStateSkippedConflict:
+				continue
+			case clusterv1alpha1.TargetStateCreated,
+				clusterv1alpha1.TargetStateUpdated,
+				clusterv1alpha1.TargetStateInSync:
+			default:
+				return false, fmt.Sprintf("%s in state %s", describeTarget(target), state), nil
+			}
+
+			var typedObject = &unstructured.Unstructured{}
+			typedObject.SetGroupVersionKind(resource.GroupVersionKind())
+			if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: resource.GetName()}, typedObject, &client.GetOptions{}); err != nil {
+				if client.IgnoreNotFound(err) == nil {
+					return false, fmt.Sprintf("%s to exist", describeTarget(target)), nil
+				}
+				return false, "", err
+			}
+
+			if healthy, reason := objectHealthy(typedObject); !healthy {
+				return false, fmt.Sprintf("%s, %s", describeTarget(target), reason), nil
+			}
+		}
+	}
+
+	return true, "", nil
+}
+
+// validate the health of a replicated object through its status. the controller of the object
+// must have observed the current generation and the Ready and Available conditions must be true.
+// objects without a status, e.g. ConfigMaps, are always healthy.
+func objectHealthy(typedObject *unstructured.Unstructured) (bool, string) {
+
+	observedGeneration, found, err := unstructured.NestedInt64(typedObject.Object, "status", "observedGeneration")
+	if err == nil && found && observedGeneration < typedObject.GetGeneration() {
+		return false, fmt.Sprintf("generation %d is not observed yet", typedObject.GetGeneration())
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(typedObject.Object, "status", "conditions")
+	for _, condition := range conditions {
+		condition, ok := condition.(map[string]any)
+		if !ok {
+			continue
+		}
+		if conditionType := condition["type"]; conditionType != "Ready" && conditionType != "Available" {
+			continue
+		}
+		if condition["status"] != string(metav1.ConditionTrue) {
+			return false, fmt.Sprintf("condition %s is %v", condition["type"], condition["status"])
+		}
+	}
+
+	return true, ""
+}
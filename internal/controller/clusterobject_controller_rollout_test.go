@@ -0,0 +1,416 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create a list of namespaces with the given names and the label tier=canary for the canaries
+func newRolloutNamespaces(names []string, canaries ...string) *corev1.NamespaceList {
+	var namespaces = &corev1.NamespaceList{}
+	for _, name := range names {
+		var namespace = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		for _, canary := range canaries {
+			if canary == name {
+				namespace.SetLabels(map[string]string{"tier": "canary"})
+			}
+		}
+		namespaces.Items = append(namespaces.Items, namespace)
+	}
+	return namespaces
+}
+
+func TestRolloutSteps(t *testing.T) {
+
+	var names = []string{"e", "d", "c", "b", "a"}
+
+	var tests = []struct {
+		name       string
+		rollout    clusterv1alpha1.ClusterObjectRollout
+		namespaces *corev1.NamespaceList
+		expected   [][]string
+		invalid    bool
+	}{
+		{
+			name:       "default batch size rounds up",
+			namespaces: newRolloutNamespaces(names),
+			expected:   [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		{
+			name:       "batch size count",
+			rollout:    clusterv1alpha1.ClusterObjectRollout{BatchSize: ptr.To(intstr.FromInt32(3))},
+			namespaces: newRolloutNamespaces(names),
+			expected:   [][]string{{"a", "b", "c"}, {"d", "e"}},
+		},
+		{
+			name:       "batch size percentage",
+			rollout:    clusterv1alpha1.ClusterObjectRollout{BatchSize: ptr.To(intstr.FromString("100%"))},
+			namespaces: newRolloutNamespaces(names),
+			expected:   [][]string{{"a", "b", "c", "d", "e"}},
+		},
+		{
+			name: "canaries by name",
+			rollout: clusterv1alpha1.ClusterObjectRollout{
+				Canary:    &clusterv1alpha1.ClusterObjectRolloutCanary{Names: []string{"d", "missing"}},
+				BatchSize: ptr.To(intstr.FromInt32(2)),
+			},
+			namespaces: newRolloutNamespaces(names),
+			expected:   [][]string{{"d"}, {"a", "b"}, {"c", "e"}},
+		},
+		{
+			name: "canaries by selector",
+			rollout: clusterv1alpha1.ClusterObjectRollout{
+				Canary: &clusterv1alpha1.ClusterObjectRolloutCanary{Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"tier": "canary"},
+				}},
+				BatchSize: ptr.To(intstr.FromString("50%")),
+			},
+			namespaces: newRolloutNamespaces(names, "e", "c"),
+			expected:   [][]string{{"c", "e"}, {"a", "b"}, {"d"}},
+		},
+		{
+			name: "only canaries",
+			rollout: clusterv1alpha1.ClusterObjectRollout{
+				Canary: &clusterv1alpha1.ClusterObjectRolloutCanary{Names: []string{"a"}},
+			},
+			namespaces: newRolloutNamespaces([]string{"a"}),
+			expected:   [][]string{{"a"}},
+		},
+		{
+			name:       "no namespaces",
+			namespaces: newRolloutNamespaces(nil),
+		},
+		{
+			name: "invalid canary selector",
+			rollout: clusterv1alpha1.ClusterObjectRollout{
+				Canary: &clusterv1alpha1.ClusterObjectRolloutCanary{Selector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Unknown"}},
+				}},
+			},
+			namespaces: newRolloutNamespaces(names),
+			invalid:    true,
+		},
+		{
+			name:       "invalid batch size",
+			rollout:    clusterv1alpha1.ClusterObjectRollout{BatchSize: ptr.To(intstr.FromString("half"))},
+			namespaces: newRolloutNamespaces(names),
+			invalid:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := rolloutSteps(&tt.rollout, tt.namespaces)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", steps)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(steps, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, steps)
+			}
+		})
+	}
+}
+
+func TestStartRollout(t *testing.T) {
+
+	var steps = [][]string{{"a"}, {"b", "c"}, {"d"}}
+
+	var tests = []struct {
+		name     string
+		dryRun   bool
+		status   *clusterv1alpha1.ClusterObjectRolloutStatus
+		steps    [][]string
+		expected map[string]struct{}
+		step     int32
+		phase    clusterv1alpha1.RolloutPhase
+	}{
+		{
+			name:     "new rollout",
+			steps:    steps,
+			expected: map[string]struct{}{"a": {}},
+			phase:    clusterv1alpha1.RolloutPhaseProgressing,
+		},
+		{
+			name: "new revision restarts the rollout",
+			status: &clusterv1alpha1.ClusterObjectRolloutStatus{
+				Revision: "old", Phase: clusterv1alpha1.RolloutPhaseCompleted, Step: 2,
+			},
+			steps:    steps,
+			expected: map[string]struct{}{"a": {}},
+			phase:    clusterv1alpha1.RolloutPhaseProgressing,
+		},
+		{
+			name: "finished steps stay updated",
+			status: &clusterv1alpha1.ClusterObjectRolloutStatus{
+				Revision: "rev", Phase: clusterv1alpha1.RolloutPhaseProgressing, Step: 1,
+			},
+			steps:    steps,
+			expected: map[string]struct{}{"a": {}, "b": {}, "c": {}},
+			step:     1,
+			phase:    clusterv1alpha1.RolloutPhaseProgressing,
+		},
+		{
+			name: "shrinking steps",
+			status: &clusterv1alpha1.ClusterObjectRolloutStatus{
+				Revision: "rev", Phase: clusterv1alpha1.RolloutPhaseProgressing, Step: 2,
+			},
+			steps:    steps[:2],
+			expected: map[string]struct{}{"a": {}, "b": {}, "c": {}},
+			step:     1,
+			phase:    clusterv1alpha1.RolloutPhaseProgressing,
+		},
+		{
+			name: "completed rollout",
+			status: &clusterv1alpha1.ClusterObjectRolloutStatus{
+				Revision: "rev", Phase: clusterv1alpha1.RolloutPhaseCompleted, Step: 2,
+			},
+			steps: steps,
+			step:  2,
+			phase: clusterv1alpha1.RolloutPhaseCompleted,
+		},
+		{
+			name:   "dry-run",
+			dryRun: true,
+			steps:  steps,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var co = &clusterv1alpha1.ClusterObject{}
+			co.Replicator.DryRun = tt.dryRun
+			co.Status.Rollout = tt.status
+
+			var updated = startRollout(co, "rev", tt.steps)
+			if !reflect.DeepEqual(updated, tt.expected) {
+				t.Errorf("expected the updated namespaces %v, got %v", tt.expected, updated)
+			}
+
+			var status = co.Status.Rollout
+			if tt.dryRun {
+				if status != nil {
+					t.Errorf("expected no rollout in dry-run mode, got %+v", status)
+				}
+				return
+			}
+			if status.Revision != "rev" || status.Step != tt.step || status.Phase != tt.phase {
+				t.Errorf("expected revision rev, step %d and phase %s, got %+v", tt.step, tt.phase, status)
+			}
+			var namespaces int32
+			for _, step := range tt.steps {
+				namespaces += int32(len(step))
+			}
+			if status.Steps != int32(len(tt.steps)) || status.Namespaces != namespaces {
+				t.Errorf("expected %d steps with %d namespaces, got %+v", len(tt.steps), namespaces, status)
+			}
+		})
+	}
+}
+
+func TestProgressRollout(t *testing.T) {
+
+	var steps = [][]string{{"a"}, {"b"}}
+	var resources = []*unstructured.Unstructured{newResource("v1", "ConfigMap", "test-cm")}
+
+	var target = func(namespace string, state clusterv1alpha1.TargetState) clusterv1alpha1.ClusterObjectTarget {
+		return clusterv1alpha1.ClusterObjectTarget{Namespace: namespace, Kind: "ConfigMap", Name: "test-cm", State: state}
+	}
+
+	var tests = []struct {
+		name     string
+		rollout  clusterv1alpha1.ClusterObjectRollout
+		step     int32
+		started  time.Duration
+		targets  []clusterv1alpha1.ClusterObjectTarget
+		expected clusterv1alpha1.RolloutPhase
+		nextStep int32
+		requeue  bool
+	}{
+		{
+			name:     "healthy step starts the next step",
+			targets:  []clusterv1alpha1.ClusterObjectTarget{target("a", clusterv1alpha1.TargetStateInSync)},
+			expected: clusterv1alpha1.RolloutPhaseProgressing,
+			nextStep: 1,
+		},
+		{
+			name:     "healthy last step completes the rollout",
+			step:     1,
+			targets:  []clusterv1alpha1.ClusterObjectTarget{target("a", clusterv1alpha1.TargetStateInSync), target("b", clusterv1alpha1.TargetStateCreated)},
+			expected: clusterv1alpha1.RolloutPhaseCompleted,
+			nextStep: 1,
+		},
+		{
+			name:     "pending object waits",
+			targets:  []clusterv1alpha1.ClusterObjectTarget{target("a", clusterv1alpha1.TargetStatePending)},
+			expected: clusterv1alpha1.RolloutPhaseProgressing,
+			requeue:  true,
+		},
+		{
+			name:     "pause waits",
+			rollout:  clusterv1alpha1.ClusterObjectRollout{Pause: &metav1.Duration{Duration: time.Hour}},
+			targets:  []clusterv1alpha1.ClusterObjectTarget{target("a", clusterv1alpha1.TargetStateInSync)},
+			expected: clusterv1alpha1.RolloutPhaseProgressing,
+			requeue:  true,
+		},
+		{
+			name:     "passed pause starts the next step",
+			rollout:  clusterv1alpha1.ClusterObjectRollout{Pause: &metav1.Duration{Duration: time.Minute}},
+			started:  time.Hour,
+			targets:  []clusterv1alpha1.ClusterObjectTarget{target("a", clusterv1alpha1.TargetStateInSync)},
+			expected: clusterv1alpha1.RolloutPhaseProgressing,
+			nextStep: 1,
+		},
+		{
+			name:     "failure halts the rollout",
+			targets:  []clusterv1alpha1.ClusterObjectTarget{target("a", clusterv1alpha1.TargetStateFailed)},
+			expected: clusterv1alpha1.RolloutPhaseHalted,
+		},
+		{
+			name:     "tolerated failure starts the next step",
+			rollout:  clusterv1alpha1.ClusterObjectRollout{MaxFailures: ptr.To(intstr.FromString("50%"))},
+			targets:  []clusterv1alpha1.ClusterObjectTarget{target("a", clusterv1alpha1.TargetStateQuarantined)},
+			expected: clusterv1alpha1.RolloutPhaseProgressing,
+			nextStep: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx = context.Background()
+			r, _ := newFakeReconciler(t,
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "test-cm"}},
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "test-cm"}})
+
+			var started = metav1.NewTime(time.Now().Add(-tt.started))
+			var co = &clusterv1alpha1.ClusterObject{}
+			co.Replicator.Rollout = &tt.rollout
+			co.Status.Summary.Desired = 2
+			co.Status.Rollout = &clusterv1alpha1.ClusterObjectRolloutStatus{
+				Revision:      "rev",
+				Phase:         clusterv1alpha1.RolloutPhaseProgressing,
+				Step:          tt.step,
+				Steps:         int32(len(steps)),
+				Namespaces:    2,
+				StepStartTime: &started,
+			}
+
+			after, err := r.progressRollout(ctx, co, steps, resources, tt.targets)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var status = co.Status.Rollout
+			if status.Phase != tt.expected || status.Step != tt.nextStep {
+				t.Errorf("expected phase %s at step %d, got phase %s at step %d: %s",
+					tt.expected, tt.nextStep, status.Phase, status.Step, status.Message)
+			}
+			if requeue := after > 0; requeue != tt.requeue {
+				t.Errorf("expected requeue %t, got %s", tt.requeue, after)
+			}
+		})
+	}
+}
+
+func TestObjectHealthy(t *testing.T) {
+
+	var tests = []struct {
+		name       string
+		generation int64
+		status     map[string]any
+		healthy    bool
+	}{
+		{
+			name:    "without status",
+			healthy: true,
+		},
+		{
+			name:       "observed generation",
+			generation: 2,
+			status:     map[string]any{"observedGeneration": int64(2)},
+			healthy:    true,
+		},
+		{
+			name:       "outdated generation",
+			generation: 3,
+			status:     map[string]any{"observedGeneration": int64(2)},
+		},
+		{
+			name: "available",
+			status: map[string]any{"conditions": []any{
+				map[string]any{"type": "Available", "status": "True"},
+				map[string]any{"type": "Progressing", "status": "False"},
+			}},
+			healthy: true,
+		},
+		{
+			name: "not ready",
+			status: map[string]any{"conditions": []any{
+				map[string]any{"type": "Ready", "status": "False"},
+			}},
+		},
+		{
+			name: "unknown availability",
+			status: map[string]any{"conditions": []any{
+				map[string]any{"type": "Available", "status": "Unknown"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typedObject = newResource("apps/v1", "Deployment", "app")
+			typedObject.SetGeneration(tt.generation)
+			if tt.status != nil {
+				typedObject.Object["status"] = tt.status
+			}
+
+			healthy, reason := objectHealthy(typedObject)
+			if healthy != tt.healthy {
+				t.Errorf("expected healthy %t, got %t: %s", tt.healthy, healthy, reason)
+			}
+			if !healthy && reason == "" {
+				t.Errorf("expected a reason for the unhealthy object")
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// the name of the field index of the clusterobjects, which contains the referenced source
+const sourceIndexField = "replicator.source"
+
+// the annotations of the source object, which are not replicated
+var sourceAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// create the key of a source object for the field index of the clusterobjects
+func sourceKey(apiVersion, kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", apiVersion, kind, namespace, name)
+}
+
+// index the clusterobjects by their referenced source
+func indexSource(obj client.Object) []string {
+
+	clusterObject, ok := obj.(*clusterv1alpha1.ClusterObject)
+	if !ok || clusterObject.Replicator.Source == nil {
+		return nil
+	}
+
+	var source = clusterObject.Replicator.Source
+	return []string{sourceKey(source.APIVersion, source.Kind, source.Namespace, source.Name)}
+}
+
+// fetch the source object of the clusterobject and convert it into a resource, which can
+// be replicated. the server managed metadata is removed and the selected keys are projected.
+func (r *ClusterObjectReconciler) sourceResource(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject) (*unstructured.Unstructured, error) {
+
+	var source = co.Replicator.Source
+
+	var typedObject = &unstructured.Unstructured{}
+	typedObject.SetAPIVersion(source.APIVersion)
+	typedObject.SetKind(source.Kind)
+
+	if err := r.Get(ctx, types.NamespacedName{
+		Namespace: source.Namespace,
+		Name:      source.Name,
+	}, typedObject, &client.GetOptions{}); err != nil {
+		return nil, fmt.Errorf("source [%s:%s/%s]: %w", source.Kind, source.Namespace, source.Name, err)
+	}
+
+	// only the content and the user defined metadata of the source are replicated
+	var resource = &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for key, value := range typedObject.Object {
+		switch key {
+		case "metadata", "status":
+		default:
+			resource.Object[key] = value
+		}
+	}
+	resource.SetName(typedObject.GetName())
+	resource.SetLabels(typedObject.GetLabels())
+
+	var annotations = typedObject.GetAnnotations()
+	for _, annotation := range sourceAnnotations {
+		delete(annotations, annotation)
+	}
+	resource.SetAnnotations(annotations)
+
+	if err := projectKeys(resource, source.Keys); err != nil {
+		return nil, fmt.Errorf("source [%s:%s/%s]: %w", source.Kind, source.Namespace, source.Name, err)
+	}
+
+	return resource, nil
+}
+
+// validate wether the given object in the namespace is the source of the clusterobject
+func isSource(
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace,
+	typedObject *unstructured.Unstructured) bool {
+
+	var source = co.Replicator.Source
+
+	return source != nil &&
+		source.Namespace == namespace.GetName() &&
+		source.Kind == typedObject.GetKind() &&
+		source.Name == typedObject.GetName()
+}
+
+// project the selected keys of data and binaryData. all keys, which are not selected,
+// are removed. a selected key, which does not exist, is an error.
+func projectKeys(
+	typedObject *unstructured.Unstructured,
+	keys []clusterv1alpha1.ClusterObjectSourceKey) error {
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var fields = []string{"data", "binaryData"}
+
+	var projected = map[string]map[string]interface{}{}
+	for _, key := range keys {
+
+		var toKey = key.ToKey
+		if toKey == "" {
+			toKey = key.Key
+		}
+
+		var found bool
+		for _, field := range fields {
+			values, _, err := unstructured.NestedMap(typedObject.Object, field)
+			if err != nil {
+				return err
+			}
+
+			value, ok := values[key.Key]
+			if !ok {
+				continue
+			}
+
+			if projected[field] == nil {
+				projected[field] = map[string]interface{}{}
+			}
+			projected[field][toKey] = value
+			found = true
+			break
+		}
+
+		if !found {
+			return fmt.Errorf("key %q does not exist", key.Key)
+		}
+	}
+
+	for _, field := range fields {
+		unstructured.RemoveNestedField(typedObject.Object, field)
+		if values, ok := projected[field]; ok {
+			typedObject.Object[field] = values
+		}
+	}
+
+	return nil
+}
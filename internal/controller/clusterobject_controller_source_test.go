@@ -0,0 +1,221 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+func TestProjectKeys(t *testing.T) {
+
+	var tests = []struct {
+		name       string
+		keys       []clusterv1alpha1.ClusterObjectSourceKey
+		data       map[string]any
+		binaryData map[string]any
+		expected   map[string]any
+		invalid    string
+	}{
+		{
+			name:     "all keys without a projection",
+			data:     map[string]any{"a": "1", "b": "2"},
+			expected: map[string]any{"data": map[string]any{"a": "1", "b": "2"}},
+		},
+		{
+			name:     "selected keys",
+			keys:     []clusterv1alpha1.ClusterObjectSourceKey{{Key: "a"}},
+			data:     map[string]any{"a": "1", "b": "2"},
+			expected: map[string]any{"data": map[string]any{"a": "1"}},
+		},
+		{
+			name:     "renamed keys",
+			keys:     []clusterv1alpha1.ClusterObjectSourceKey{{Key: "a", ToKey: "c"}},
+			data:     map[string]any{"a": "1", "b": "2"},
+			expected: map[string]any{"data": map[string]any{"c": "1"}},
+		},
+		{
+			name:       "keys of binary data",
+			keys:       []clusterv1alpha1.ClusterObjectSourceKey{{Key: "a"}, {Key: "bin"}},
+			data:       map[string]any{"a": "1"},
+			binaryData: map[string]any{"bin": "AQI=", "other": "AwQ="},
+			expected: map[string]any{
+				"data":       map[string]any{"a": "1"},
+				"binaryData": map[string]any{"bin": "AQI="},
+			},
+		},
+		{
+			name:    "missing key",
+			keys:    []clusterv1alpha1.ClusterObjectSourceKey{{Key: "missing"}},
+			data:    map[string]any{"a": "1"},
+			invalid: `key "missing" does not exist`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resource = newResource("v1", "ConfigMap", "source")
+			if tt.data != nil {
+				resource.Object["data"] = tt.data
+			}
+			if tt.binaryData != nil {
+				resource.Object["binaryData"] = tt.binaryData
+			}
+
+			err := projectKeys(resource, tt.keys)
+			if tt.invalid != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.invalid) {
+					t.Fatalf("expected an error containing %q, got %v", tt.invalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, field := range []string{"data", "binaryData"} {
+				if !reflect.DeepEqual(resource.Object[field], tt.expected[field]) {
+					t.Errorf("expected %s %v, got %v", field, tt.expected[field], resource.Object[field])
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileReplicatesSource(t *testing.T) {
+
+	var ctx = context.Background()
+
+	var source = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "registry",
+			Labels:    map[string]string{"team": "platform"},
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+		Data: map[string][]byte{"username": []byte("user"), "password": []byte("secret")},
+	}
+
+	var co = &clusterv1alpha1.ClusterObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "source", UID: "source-uid", Generation: 1},
+		Replicator: clusterv1alpha1.ClusterObjectReplicator{
+			TargetAll: true,
+			Source: &clusterv1alpha1.ClusterObjectSource{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Namespace:  "source",
+				Name:       "registry",
+				Keys:       []clusterv1alpha1.ClusterObjectSourceKey{{Key: "password", ToKey: "token"}},
+			},
+		},
+	}
+
+	r, c := newFakeReconciler(t, co, source,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "source"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		if target.Namespace == "source" {
+			t.Fatalf("expected the source not to be replicated into its own namespace, got %+v", target)
+		}
+		if target.State != clusterv1alpha1.TargetStateCreated {
+			t.Fatalf("expected the object to be created, got %s: %s", target.State, target.LastError)
+		}
+	}
+
+	var replicated = &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "registry"}, replicated); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replicated.Data, map[string][]byte{"token": []byte("secret")}) {
+		t.Errorf("expected only the projected key, got %v", replicated.Data)
+	}
+	if replicated.Labels["team"] != "platform" {
+		t.Errorf("expected the labels of the source, got %v", replicated.Labels)
+	}
+	if _, ok := replicated.Annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		t.Errorf("expected the last applied configuration to be removed, got %v", replicated.Annotations)
+	}
+
+	// the source itself is neither controlled nor changed by the clusterobject
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "source", Name: "registry"}, source); err != nil {
+		t.Fatal(err)
+	}
+	if metav1.GetControllerOf(source) != nil || len(source.Data) != 2 {
+		t.Errorf("expected the source to be unchanged, got %+v", source)
+	}
+}
+
+func TestReconcileReportsMissingSource(t *testing.T) {
+
+	var co = &clusterv1alpha1.ClusterObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "missing-source", UID: "missing-source-uid", Generation: 1},
+		Replicator: clusterv1alpha1.ClusterObjectReplicator{
+			TargetAll: true,
+			Source: &clusterv1alpha1.ClusterObjectSource{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Namespace:  "source",
+				Name:       "registry",
+			},
+		},
+	}
+
+	r, _ := newFakeReconciler(t, co)
+
+	_, err := r.sourceResource(context.Background(), co)
+	if !apierrors.IsNotFound(err) || !strings.Contains(err.Error(), "source [Secret:source/registry]") {
+		t.Fatalf("expected a not found error, which names the source, got %v", err)
+	}
+}
+
+func TestIndexSource(t *testing.T) {
+
+	var co = &clusterv1alpha1.ClusterObject{}
+	if keys := indexSource(co); keys != nil {
+		t.Errorf("expected no index without a source, got %v", keys)
+	}
+
+	co.Replicator.Source = &clusterv1alpha1.ClusterObjectSource{APIVersion: "v1", Kind: "Secret", Namespace: "source", Name: "registry"}
+	if keys := indexSource(co); !reflect.DeepEqual(keys, []string{"v1/Secret/source/registry"}) {
+		t.Errorf("expected the key of the source, got %v", keys)
+	}
+
+	if keys := indexSource(&unstructured.Unstructured{}); keys != nil {
+		t.Errorf("expected no index for other objects, got %v", keys)
+	}
+}
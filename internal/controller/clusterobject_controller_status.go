@@ -0,0 +1,358 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+const (
+	// maximum number of targets, which are listed in the status of a clusterobject.
+	// this keeps the clusterobject inside the size limits of etcd, even if thousands
+	// of namespaces are selected.
+	maxTargetStatuses = 250
+
+	// maximum length of an error message in the status of a target
+	maxTargetErrorLength = 512
+
+	// DefaultMaxTargetFailures is the default number of consecutive failures, after
+	// which a target namespace is quarantined
+	DefaultMaxTargetFailures int32 = 5
+
+	// the backoff of failed target namespaces starts with the base delay and
+	// doubles with every failure, until the maximum delay is reached
+	targetRetryBaseDelay = 10 * time.Second
+	targetRetryMaxDelay  = 10 * time.Minute
+
+	// quarantined target namespaces are only retried once per interval, or if
+	// the clusterobject changes
+	targetQuarantineInterval = time.Hour
+)
+
+// the order, in which targets are kept in the status, if the list has to be truncated.
+// namespaces, which require attention, are listed first.
+var targetStatePriority = map[clusterv1alpha1.TargetState]int{
+	clusterv1alpha1.TargetStateQuarantined:     0,
+	clusterv1alpha1.TargetStateFailed:          1,
+	clusterv1alpha1.TargetStateSkippedConflict: 2,
+	clusterv1alpha1.TargetStateDeleted:         3,
+	clusterv1alpha1.TargetStatePlanned:         4,
+	clusterv1alpha1.TargetStatePending:         5,
+	clusterv1alpha1.TargetStateWaiting:         6,
+	clusterv1alpha1.TargetStateCreated:         7,
+	clusterv1alpha1.TargetStateUpdated:         8,
+	clusterv1alpha1.TargetStateInSync:          9,
+}
+
+// calculate the status of all targets of the clusterobject. the status is only changed
+// in the given object, it is written to the cluster with the next condition update.
+func (r *ClusterObjectReconciler) setTargetsStatus(
+	co *clusterv1alpha1.ClusterObject,
+	desired int32,
+	targets []clusterv1alpha1.ClusterObjectTarget) {
+
+	var summary = clusterv1alpha1.ClusterObjectSummary{Desired: desired}
+
+	var list = make([]clusterv1alpha1.ClusterObjectTarget, 0, len(targets))
+	for _, target := range targets {
+
+		target = r.mergeTargetStatus(co, target)
+
+		switch target.State {
+		case clusterv1alpha1.TargetStateCreated,
+			clusterv1alpha1.TargetStateUpdated,
+			clusterv1alpha1.TargetStateInSync:
+			summary.Synced++
+		case clusterv1alpha1.TargetStateFailed:
+			summary.Failed++
+		case clusterv1alpha1.TargetStateSkippedConflict:
+			summary.Skipped++
+		case clusterv1alpha1.TargetStateQuarantined:
+			summary.Quarantined++
+		case clusterv1alpha1.TargetStatePlanned:
+			summary.Planned++
+		case clusterv1alpha1.TargetStatePending:
+			summary.Pending++
+		case clusterv1alpha1.TargetStateWaiting:
+			summary.Waiting++
+		}
+
+		list = append(list, target)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if pi, pj := targetStatePriority[list[i].State], targetStatePriority[list[j].State]; pi != pj {
+			return pi < pj
+		}
+		if list[i].Namespace != list[j].Namespace {
+			return list[i].Namespace < list[j].Namespace
+		}
+		if list[i].Kind != list[j].Kind {
+			return list[i].Kind < list[j].Kind
+		}
+		if list[i].APIVersion != list[j].APIVersion {
+			return list[i].APIVersion < list[j].APIVersion
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	co.Status.OmittedTargets = 0
+	if len(list) > maxTargetStatuses {
+		co.Status.OmittedTargets = int32(len(list) - maxTargetStatuses)
+		list = list[:maxTargetStatuses]
+	}
+
+	co.Status.Targets = list
+	co.Status.Summary = summary
+	co.Status.ObservedGeneration = co.GetGeneration()
+}
+
+// find the previous status of an object in a target namespace. the previous status is only
+// considered, if it was calculated for the current generation of the clusterobject.
+func previousTargetStatus(
+	co *clusterv1alpha1.ClusterObject,
+	namespace string,
+	apiVersion string,
+	kind string,
+	name string) *clusterv1alpha1.ClusterObjectTarget {
+
+	if co.Status.ObservedGeneration != co.GetGeneration() {
+		return nil
+	}
+
+	for i := range co.Status.Targets {
+		if co.Status.Targets[i].Namespace == namespace &&
+			co.Status.Targets[i].APIVersion == apiVersion &&
+			co.Status.Targets[i].Kind == kind &&
+			co.Status.Targets[i].Name == name {
+			return &co.Status.Targets[i]
+		}
+	}
+
+	return nil
+}
+
+// merge the new state of a target with the state of the previous reconciliation.
+// the sync time only changes, if the object was in fact written, otherwise every
+// reconciliation would change the status and trigger the next reconciliation.
+// failed targets get their backoff and are quarantined, if they failed too often.
+func (r *ClusterObjectReconciler) mergeTargetStatus(
+	co *clusterv1alpha1.ClusterObject,
+	target clusterv1alpha1.ClusterObjectTarget) clusterv1alpha1.ClusterObjectTarget {
+
+	var previous = previousTargetStatus(co, target.Namespace, target.APIVersion, target.Kind, target.Name)
+
+	var now = metav1.Now()
+
+	switch target.State {
+	case clusterv1alpha1.TargetStateCreated,
+		clusterv1alpha1.TargetStateUpdated,
+		clusterv1alpha1.TargetStateDeleted:
+		target.LastSyncTime = &now
+	default:
+		if previous != nil {
+			target.LastSyncTime = previous.LastSyncTime
+		}
+	}
+
+	// targets, which are still waiting for their retry, are kept as they are
+	if target.State == clusterv1alpha1.TargetStateFailed && target.NextRetryTime == nil {
+		target.Failures = 1
+		if previous != nil {
+			target.Failures = previous.Failures + 1
+		}
+
+		var maxFailures = r.MaxTargetFailures
+		if maxFailures <= 0 {
+			maxFailures = DefaultMaxTargetFailures
+		}
+
+		var delay = targetQuarantineInterval
+		if target.Failures >= maxFailures {
+			target.State = clusterv1alpha1.TargetStateQuarantined
+		} else {
+			delay = targetRetryBaseDelay << (target.Failures - 1)
+			if delay > targetRetryMaxDelay || delay <= 0 {
+				delay = targetRetryMaxDelay
+			}
+		}
+
+		var nextRetryTime = metav1.NewTime(now.Add(delay))
+		target.NextRetryTime = &nextRetryTime
+	}
+
+	if len(target.LastError) > maxTargetErrorLength {
+		target.LastError = target.LastError[:maxTargetErrorLength]
+	}
+
+	return target
+}
+
+// return the previous status of a failed or quarantined object in a target namespace, if
+// its backoff did not expire yet. otherwise the object should be reconciled and nil is
+// returned.
+func (r *ClusterObjectReconciler) pendingRetry(
+	co *clusterv1alpha1.ClusterObject,
+	namespace string,
+	typedObject *unstructured.Unstructured) *clusterv1alpha1.ClusterObjectTarget {
+
+	var previous = previousTargetStatus(co, namespace, typedObject.GetAPIVersion(), typedObject.GetKind(), typedObject.GetName())
+	if previous == nil || previous.NextRetryTime == nil {
+		return nil
+	}
+
+	if previous.State != clusterv1alpha1.TargetStateFailed &&
+		previous.State != clusterv1alpha1.TargetStateQuarantined {
+		return nil
+	}
+
+	if !time.Now().Before(previous.NextRetryTime.Time) {
+		return nil
+	}
+
+	var target = *previous
+	return &target
+}
+
+// calculate the duration until the next failed target namespace should be retried
+func nextRetryAfter(co *clusterv1alpha1.ClusterObject) time.Duration {
+
+	var next time.Duration
+	for _, target := range co.Status.Targets {
+		if target.NextRetryTime == nil {
+			continue
+		}
+		var after = time.Until(target.NextRetryTime.Time)
+		if after < time.Second {
+			after = time.Second
+		}
+		if next == 0 || after < next {
+			next = after
+		}
+	}
+
+	return next
+}
+
+// create the target of an object, which failed in a namespace
+func failedTarget(
+	namespace corev1.Namespace,
+	typedObject *unstructured.Unstructured,
+	err error) clusterv1alpha1.ClusterObjectTarget {
+
+	return clusterv1alpha1.ClusterObjectTarget{
+		Namespace:  namespace.GetName(),
+		APIVersion: typedObject.GetAPIVersion(),
+		Kind:       typedObject.GetKind(),
+		Name:       typedObject.GetName(),
+		State:      clusterv1alpha1.TargetStateFailed,
+		LastError:  err.Error(),
+	}
+}
+
+// describe a target for messages
+func describeTarget(target clusterv1alpha1.ClusterObjectTarget) string {
+	return fmt.Sprintf("%s:%s/%s", target.Kind, target.Namespace, target.Name)
+}
+
+// list the objects of all targets with the given state
+func targetObjects(
+	targets []clusterv1alpha1.ClusterObjectTarget,
+	state clusterv1alpha1.TargetState) []string {
+
+	var objects []string
+	for _, target := range targets {
+		if target.State == state {
+			objects = append(objects, describeTarget(target))
+		}
+	}
+
+	return objects
+}
+
+// list the objects of all targets with the given planned action
+func plannedObjects(
+	targets []clusterv1alpha1.ClusterObjectTarget,
+	action clusterv1alpha1.TargetAction) []string {
+
+	var objects []string
+	for _, target := range targets {
+		if target.State == clusterv1alpha1.TargetStatePlanned && target.PlannedAction == action {
+			objects = append(objects, describeTarget(target))
+		}
+	}
+
+	return objects
+}
+
+// calculate the target of a namespace in dry-run mode. the api server does not persist
+// dry-run requests, so the resourceVersion can not be used to detect changes. instead the
+// live object is compared with the result of the dry-run.
+func plannedTarget(
+	namespace corev1.Namespace,
+	doesExist bool,
+	liveObject *unstructured.Unstructured,
+	appliedObject *unstructured.Unstructured) *clusterv1alpha1.ClusterObjectTarget {
+
+	var target = &clusterv1alpha1.ClusterObjectTarget{
+		Namespace:     namespace.GetName(),
+		APIVersion:    appliedObject.GetAPIVersion(),
+		Kind:          appliedObject.GetKind(),
+		Name:          appliedObject.GetName(),
+		State:         clusterv1alpha1.TargetStatePlanned,
+		PlannedAction: clusterv1alpha1.TargetActionCreate,
+	}
+
+	if !doesExist {
+		return target
+	}
+
+	target.ResourceVersion = liveObject.GetResourceVersion()
+	target.PlannedAction = clusterv1alpha1.TargetActionUpdate
+
+	// the server managed metadata is not relevant for the comparison
+	var live, applied = liveObject.DeepCopy(), appliedObject.DeepCopy()
+	for _, obj := range []*unstructured.Unstructured{live, applied} {
+		obj.SetResourceVersion("")
+		obj.SetManagedFields(nil)
+		obj.SetGeneration(0)
+	}
+
+	if equality.Semantic.DeepEqual(live.Object, applied.Object) {
+		target.State = clusterv1alpha1.TargetStateInSync
+		target.PlannedAction = ""
+	}
+
+	return target
+}
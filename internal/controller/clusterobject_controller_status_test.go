@@ -0,0 +1,444 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+func TestMergeTargetStatus(t *testing.T) {
+
+	var lastSync = metav1.NewTime(time.Now().Add(-time.Hour))
+
+	var tests = []struct {
+		name              string
+		maxFailures       int32
+		previous          *clusterv1alpha1.ClusterObjectTarget
+		observed          int64
+		target            clusterv1alpha1.ClusterObjectTarget
+		state             clusterv1alpha1.TargetState
+		failures          int32
+		delay             time.Duration
+		keepsLastSyncTime bool
+	}{
+		{
+			name:     "first failure",
+			target:   clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed},
+			state:    clusterv1alpha1.TargetStateFailed,
+			failures: 1,
+			delay:    targetRetryBaseDelay,
+		},
+		{
+			name:     "backoff doubles",
+			previous: &clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed, Failures: 2},
+			target:   clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed},
+			state:    clusterv1alpha1.TargetStateFailed,
+			failures: 3,
+			delay:    4 * targetRetryBaseDelay,
+		},
+		{
+			name:        "backoff is limited",
+			maxFailures: 100,
+			previous:    &clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed, Failures: 40},
+			target:      clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed},
+			state:       clusterv1alpha1.TargetStateFailed,
+			failures:    41,
+			delay:       targetRetryMaxDelay,
+		},
+		{
+			name:     "quarantine after the default maximum of failures",
+			previous: &clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed, Failures: DefaultMaxTargetFailures - 1},
+			target:   clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed},
+			state:    clusterv1alpha1.TargetStateQuarantined,
+			failures: DefaultMaxTargetFailures,
+			delay:    targetQuarantineInterval,
+		},
+		{
+			name:        "quarantine after the configured maximum of failures",
+			maxFailures: 2,
+			previous:    &clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed, Failures: 1},
+			target:      clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed},
+			state:       clusterv1alpha1.TargetStateQuarantined,
+			failures:    2,
+			delay:       targetQuarantineInterval,
+		},
+		{
+			name:     "failures of an older generation are reset",
+			observed: 1,
+			previous: &clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed, Failures: 4},
+			target:   clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed},
+			state:    clusterv1alpha1.TargetStateFailed,
+			failures: 1,
+			delay:    targetRetryBaseDelay,
+		},
+		{
+			name:              "success resets the failures",
+			previous:          &clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateFailed, Failures: 3, LastSyncTime: &lastSync},
+			target:            clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateInSync},
+			state:             clusterv1alpha1.TargetStateInSync,
+			keepsLastSyncTime: true,
+		},
+		{
+			name:     "update changes the sync time",
+			previous: &clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateInSync, LastSyncTime: &lastSync},
+			target:   clusterv1alpha1.ClusterObjectTarget{State: clusterv1alpha1.TargetStateUpdated},
+			state:    clusterv1alpha1.TargetStateUpdated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = &ClusterObjectReconciler{MaxTargetFailures: tt.maxFailures}
+
+			var co = &clusterv1alpha1.ClusterObject{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+			co.Status.ObservedGeneration = 2
+			if tt.observed != 0 {
+				co.Status.ObservedGeneration = tt.observed
+			}
+
+			var target = tt.target
+			target.Namespace, target.Kind, target.Name = "app", "ConfigMap", "test-cm"
+			if tt.previous != nil {
+				var previous = *tt.previous
+				previous.Namespace, previous.Kind, previous.Name = "app", "ConfigMap", "test-cm"
+				co.Status.Targets = []clusterv1alpha1.ClusterObjectTarget{previous}
+			}
+
+			var start = time.Now()
+			var merged = r.mergeTargetStatus(co, target)
+
+			if merged.State != tt.state || merged.Failures != tt.failures {
+				t.Fatalf("expected state %s with %d failures, got state %s with %d failures",
+					tt.state, tt.failures, merged.State, merged.Failures)
+			}
+
+			if tt.delay == 0 {
+				if merged.NextRetryTime != nil {
+					t.Errorf("expected no retry, got %s", merged.NextRetryTime)
+				}
+			} else {
+				if merged.NextRetryTime == nil {
+					t.Fatalf("expected a retry after %s", tt.delay)
+				}
+				if delay := merged.NextRetryTime.Sub(start); delay < tt.delay-time.Second || delay > tt.delay+time.Second {
+					t.Errorf("expected a retry after %s, got %s", tt.delay, delay)
+				}
+			}
+
+			if tt.keepsLastSyncTime && (merged.LastSyncTime == nil || !merged.LastSyncTime.Equal(&lastSync)) {
+				t.Errorf("expected the last sync time to be kept, got %v", merged.LastSyncTime)
+			}
+			if tt.state == clusterv1alpha1.TargetStateUpdated && (merged.LastSyncTime == nil || !merged.LastSyncTime.After(lastSync.Time)) {
+				t.Errorf("expected a new sync time, got %v", merged.LastSyncTime)
+			}
+		})
+	}
+}
+
+func TestMergeTargetStatusKeepsPendingRetry(t *testing.T) {
+
+	var r = &ClusterObjectReconciler{}
+	var co = &clusterv1alpha1.ClusterObject{}
+
+	// a target, which is still waiting for its retry, is not counted again
+	var nextRetryTime = metav1.NewTime(time.Now().Add(time.Minute))
+	var target = clusterv1alpha1.ClusterObjectTarget{
+		Namespace:     "app",
+		APIVersion:    "v1",
+		Kind:          "ConfigMap",
+		Name:          "test-cm",
+		State:         clusterv1alpha1.TargetStateFailed,
+		Failures:      2,
+		NextRetryTime: &nextRetryTime,
+	}
+
+	var merged = r.mergeTargetStatus(co, target)
+	if merged.Failures != 2 || !merged.NextRetryTime.Equal(&nextRetryTime) {
+		t.Errorf("expected the pending retry to be kept, got %+v", merged)
+	}
+}
+
+func TestMergeTargetStatusTruncatesError(t *testing.T) {
+
+	var r = &ClusterObjectReconciler{}
+	var co = &clusterv1alpha1.ClusterObject{}
+
+	var target = clusterv1alpha1.ClusterObjectTarget{
+		State:     clusterv1alpha1.TargetStateFailed,
+		LastError: string(make([]byte, 2*maxTargetErrorLength)),
+	}
+
+	if merged := r.mergeTargetStatus(co, target); len(merged.LastError) != maxTargetErrorLength {
+		t.Errorf("expected the error to be truncated to %d characters, got %d", maxTargetErrorLength, len(merged.LastError))
+	}
+}
+
+func TestSetTargetsStatusSeparatesGroups(t *testing.T) {
+
+	var r = &ClusterObjectReconciler{}
+	var co = &clusterv1alpha1.ClusterObject{ObjectMeta: metav1.ObjectMeta{Generation: 1}}
+	co.Status.ObservedGeneration = 1
+
+	// the same kind and name in two groups are two targets with their own failures
+	var extensions = clusterv1alpha1.ClusterObjectTarget{
+		Namespace:  "app",
+		APIVersion: "extensions/v1beta1",
+		Kind:       "Ingress",
+		Name:       "web",
+		State:      clusterv1alpha1.TargetStateFailed,
+	}
+	var networking = extensions
+	networking.APIVersion = "networking.k8s.io/v1"
+
+	var previous = extensions
+	previous.Failures = 3
+	co.Status.Targets = []clusterv1alpha1.ClusterObjectTarget{previous}
+
+	r.setTargetsStatus(co, 2, []clusterv1alpha1.ClusterObjectTarget{extensions, networking})
+
+	if len(co.Status.Targets) != 2 {
+		t.Fatalf("expected 2 targets, got %+v", co.Status.Targets)
+	}
+	for _, target := range co.Status.Targets {
+		var expected int32 = 1
+		if target.APIVersion == extensions.APIVersion {
+			expected = 4
+		}
+		if target.Failures != expected {
+			t.Errorf("expected %d failures for %s, got %d", expected, target.APIVersion, target.Failures)
+		}
+	}
+}
+
+func TestReconcileContinuesAfterFailedNamespace(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("failing", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "broken"}})
+
+	// every apply into the namespace broken fails
+	var brokenApplies atomic.Int32
+	r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+		Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+			var object struct {
+				Metadata metav1.ObjectMeta `json:"metadata"`
+			}
+			content, err := json.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(content, &object); err != nil {
+				return err
+			}
+			if object.Metadata.Namespace == "broken" {
+				brokenApplies.Add(1)
+				return errors.New("admission denied")
+			}
+			return c.Apply(ctx, obj, opts...)
+		},
+	})
+
+	var request = ctrl.Request{NamespacedName: types.NamespacedName{Name: co.GetName()}}
+	for range 2 {
+		result, err := r.Reconcile(ctx, request)
+		if err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		if result.RequeueAfter <= 0 || result.RequeueAfter > targetRetryBaseDelay {
+			t.Fatalf("expected a requeue with the backoff of the failed namespace, got %s", result.RequeueAfter)
+		}
+	}
+
+	// the failed namespace is not retried, until its backoff expired
+	if brokenApplies.Load() != 1 {
+		t.Errorf("expected a single apply into the failed namespace, got %d", brokenApplies.Load())
+	}
+
+	if err := c.Get(ctx, request.NamespacedName, co); err != nil {
+		t.Fatal(err)
+	}
+	if co.Status.Summary.Failed != 1 || co.Status.Summary.Synced != 1 {
+		t.Errorf("expected 1 failed and 1 synced object, got %+v", co.Status.Summary)
+	}
+	for _, target := range co.Status.Targets {
+		switch target.Namespace {
+		case "broken":
+			if target.State != clusterv1alpha1.TargetStateFailed || target.Failures != 1 || target.NextRetryTime == nil {
+				t.Errorf("expected a failed target waiting for its retry, got %+v", target)
+			}
+		case "app":
+			if target.State == clusterv1alpha1.TargetStateFailed {
+				t.Errorf("expected the other namespace to be synced, got %+v", target)
+			}
+		}
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, &corev1.ConfigMap{}); err != nil {
+		t.Errorf("expected the object in the other namespace to be created, got %v", err)
+	}
+}
+
+func TestPlannedTarget(t *testing.T) {
+
+	var namespace = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}}
+
+	var newObject = func(value string, resourceVersion string) *unstructured.Unstructured {
+		var obj = &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"namespace": "app", "name": "test-cm"},
+			"data":       map[string]any{"key": value},
+		}}
+		obj.SetResourceVersion(resourceVersion)
+		return obj
+	}
+
+	var tests = []struct {
+		name      string
+		doesExist bool
+		live      *unstructured.Unstructured
+		applied   *unstructured.Unstructured
+		state     clusterv1alpha1.TargetState
+		action    clusterv1alpha1.TargetAction
+	}{
+		{
+			name:    "missing object",
+			applied: newObject("value", ""),
+			state:   clusterv1alpha1.TargetStatePlanned,
+			action:  clusterv1alpha1.TargetActionCreate,
+		},
+		{
+			name:      "changed object",
+			doesExist: true,
+			live:      newObject("old", "1"),
+			applied:   newObject("value", "1"),
+			state:     clusterv1alpha1.TargetStatePlanned,
+			action:    clusterv1alpha1.TargetActionUpdate,
+		},
+		{
+			name:      "unchanged object",
+			doesExist: true,
+			live:      newObject("value", "1"),
+			applied:   newObject("value", "2"),
+			state:     clusterv1alpha1.TargetStateInSync,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target = plannedTarget(namespace, tt.doesExist, tt.live, tt.applied)
+			if target.State != tt.state || target.PlannedAction != tt.action {
+				t.Errorf("expected %s/%s, got %s/%s", tt.state, tt.action, target.State, target.PlannedAction)
+			}
+		})
+	}
+}
+
+func TestReconcilePlansActionsInDryRun(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("dry-run", map[string]any{"key": "value"})
+	r, c := newFakeReconciler(t, co, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	// the fake client persists dry-run applies, the api server does not
+	r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+		Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+			var applyOpts = &client.ApplyOptions{}
+			if applyOpts.ApplyOptions(opts); len(applyOpts.DryRun) > 0 {
+				return nil
+			}
+			return c.Apply(ctx, obj, opts...)
+		},
+	})
+
+	reconcileTargets(t, r, co.GetName())
+
+	// a new namespace would get the object, the existing one would be updated
+	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	co.Replicator.DryRun = true
+	co.Replicator.Resource.Object["data"] = map[string]any{"key": "changed"}
+	if err := c.Update(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+
+	var actions = map[string]clusterv1alpha1.TargetAction{}
+	for _, target := range reconcileTargets(t, r, co.GetName()) {
+		actions[target.Namespace] = target.PlannedAction
+	}
+	if actions["app"] != clusterv1alpha1.TargetActionUpdate || actions["new"] != clusterv1alpha1.TargetActionCreate {
+		t.Fatalf("expected an update of app and a create in new, got %v", actions)
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "new", Name: "test-cm"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no object to be created in dry-run mode, got %v", err)
+	}
+	var cm = &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["key"] != "value" {
+		t.Errorf("expected the object to be unchanged in dry-run mode, got %v", cm.Data)
+	}
+
+	// a deselected namespace would be pruned
+	if err := c.Get(ctx, types.NamespacedName{Name: co.GetName()}, co); err != nil {
+		t.Fatal(err)
+	}
+	co.Replicator.TargetAll = false
+	if err := c.Update(ctx, co); err != nil {
+		t.Fatal(err)
+	}
+
+	var targets = reconcileTargets(t, r, co.GetName())
+	if len(targets) != 1 || targets[0].Namespace != "app" || targets[0].PlannedAction != clusterv1alpha1.TargetActionDelete {
+		t.Fatalf("expected a planned delete in app, got %+v", targets)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "app", Name: "test-cm"}, &corev1.ConfigMap{}); err != nil {
+		t.Errorf("expected the object to be kept in dry-run mode, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// the functions, which are available in the templates. only the hermetic functions of
+// sprig are used, functions like random values or the current time would render a
+// different object with every reconciliation.
+var templateFuncs = sprig.HermeticTxtFuncMap()
+
+// templateData is the data, which is available in the templates of a resource
+type templateData struct {
+	Namespace templateNamespace
+}
+
+// templateNamespace contains the fields of the target namespace, which are available
+// in the templates
+type templateNamespace struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// render the given object as a template for the given namespace. all string values and
+// keys are rendered, the identity of the object (apiVersion, kind and name) is kept, so
+// the object can be found in every namespace.
+func renderTemplate(
+	typedObject *unstructured.Unstructured,
+	namespace corev1.Namespace) (*unstructured.Unstructured, error) {
+
+	var data = templateData{
+		Namespace: templateNamespace{
+			Name:        namespace.GetName(),
+			Labels:      namespace.GetLabels(),
+			Annotations: namespace.GetAnnotations(),
+		},
+	}
+
+	rendered, err := renderValue(typedObject.Object, "", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var renderedObject = &unstructured.Unstructured{Object: rendered.(map[string]interface{})}
+	renderedObject.SetAPIVersion(typedObject.GetAPIVersion())
+	renderedObject.SetKind(typedObject.GetKind())
+	renderedObject.SetName(typedObject.GetName())
+
+	return renderedObject, nil
+}
+
+// render a value of an unstructured object recursively. the path of the value is used
+// as the name of the template, so errors point to the failing field.
+func renderValue(value interface{}, path string, data templateData) (interface{}, error) {
+
+	switch typedValue := value.(type) {
+
+	case string:
+		return renderString(typedValue, path, data)
+
+	case map[string]interface{}:
+		var rendered = make(map[string]interface{}, len(typedValue))
+		for key, item := range typedValue {
+			var itemPath = strings.TrimPrefix(path+"."+key, ".")
+
+			renderedKey, err := renderString(key, itemPath, data)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := rendered[renderedKey]; ok {
+				return nil, fmt.Errorf("template %s: rendered key %q is not unique", itemPath, renderedKey)
+			}
+
+			if rendered[renderedKey], err = renderValue(item, itemPath, data); err != nil {
+				return nil, err
+			}
+		}
+		return rendered, nil
+
+	case []interface{}:
+		var rendered = make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			var err error
+			if rendered[i], err = renderValue(item, fmt.Sprintf("%s[%d]", path, i), data); err != nil {
+				return nil, err
+			}
+		}
+		return rendered, nil
+
+	default:
+		return value, nil
+	}
+}
+
+// render a single string, strings without actions are returned as they are. missing keys
+// are reported as errors, so a typo does not render "<no value>" into every namespace.
+func renderString(value, name string, data templateData) (string, error) {
+
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	tpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,175 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRenderTemplate(t *testing.T) {
+
+	var namespace = corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		Labels:      map[string]string{"env": "prod"},
+		Annotations: map[string]string{"example.com/owner": "alice"},
+	}}
+
+	var tests = []struct {
+		name     string
+		data     map[string]any
+		expected map[string]any
+		invalid  string
+	}{
+		{
+			name:     "namespace name",
+			data:     map[string]any{"url": "https://{{ .Namespace.Name }}.example.com"},
+			expected: map[string]any{"url": "https://team-a.example.com"},
+		},
+		{
+			name: "labels and annotations",
+			data: map[string]any{
+				"env":   "{{ .Namespace.Labels.env }}",
+				"owner": `{{ index .Namespace.Annotations "example.com/owner" }}`,
+			},
+			expected: map[string]any{"env": "prod", "owner": "alice"},
+		},
+		{
+			name:     "sprig functions",
+			data:     map[string]any{"env": `{{ index .Namespace.Labels "tier" | default "none" | upper }}`},
+			expected: map[string]any{"env": "NONE"},
+		},
+		{
+			name:     "keys",
+			data:     map[string]any{"{{ .Namespace.Name }}.conf": "enabled"},
+			expected: map[string]any{"team-a.conf": "enabled"},
+		},
+		{
+			name:     "strings without actions",
+			data:     map[string]any{"plain": "value", "braces": "{ .Namespace.Name }"},
+			expected: map[string]any{"plain": "value", "braces": "{ .Namespace.Name }"},
+		},
+		{
+			name: "nested values",
+			data: map[string]any{"list": []any{
+				"{{ .Namespace.Name }}",
+				map[string]any{"count": int64(1), "enabled": true, "name": "{{ .Namespace.Name }}"},
+			}},
+			expected: map[string]any{"list": []any{
+				"team-a",
+				map[string]any{"count": int64(1), "enabled": true, "name": "team-a"},
+			}},
+		},
+		{
+			name:    "duplicate rendered keys",
+			data:    map[string]any{"{{ .Namespace.Name }}": "a", "team-a": "b"},
+			invalid: "is not unique",
+		},
+		{
+			name:    "missing label",
+			data:    map[string]any{"env": "{{ .Namespace.Labels.tier }}"},
+			invalid: "map has no entry for key",
+		},
+		{
+			name:    "missing annotation in the key",
+			data:    map[string]any{"{{ .Namespace.Annotations.owner }}": "value"},
+			invalid: "map has no entry for key",
+		},
+		{
+			name:    "parse error names the field",
+			data:    map[string]any{"broken": "{{ .Namespace.Name "},
+			invalid: "data.broken",
+		},
+		{
+			name:    "execution error",
+			data:    map[string]any{"failing": `{{ fail "missing value" }}`},
+			invalid: "missing value",
+		},
+		{
+			name:    "non-hermetic functions are not available",
+			data:    map[string]any{"random": "{{ randAlpha 8 }}"},
+			invalid: "randAlpha",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typedObject = newResource("v1", "ConfigMap", "{{ .Namespace.Name }}-cm")
+			typedObject.Object["data"] = tt.data
+
+			rendered, err := renderTemplate(typedObject, namespace)
+			if tt.invalid != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.invalid) {
+					t.Fatalf("expected an error containing %q, got %v", tt.invalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if data := rendered.Object["data"]; !reflect.DeepEqual(data, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, data)
+			}
+
+			// the identity of the object is never rendered
+			if rendered.GetName() != "{{ .Namespace.Name }}-cm" || rendered.GetKind() != "ConfigMap" {
+				t.Errorf("expected the identity to be kept, got %s/%s", rendered.GetKind(), rendered.GetName())
+			}
+
+			// the given object is not changed
+			if !reflect.DeepEqual(typedObject.Object["data"], tt.data) {
+				t.Errorf("expected the given object to be kept, got %v", typedObject.Object["data"])
+			}
+		})
+	}
+}
+
+func TestRenderTemplateKeepsUnstructuredTypes(t *testing.T) {
+
+	var typedObject = &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "app", "labels": map[string]any{"team": "{{ .Namespace.Name }}"}},
+		"spec":       map[string]any{"replicas": int64(2), "paused": false},
+	}}
+
+	rendered, err := renderTemplate(typedObject, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if labels := rendered.GetLabels(); labels["team"] != "team-a" {
+		t.Errorf("expected the rendered label, got %v", labels)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(rendered.Object, "spec", "replicas"); replicas != 2 {
+		t.Errorf("expected 2 replicas, got %d", replicas)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+const (
+	// ManagedByLabel marks every replicated object as managed by r8r
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+
+	// ClusterObjectLabel contains the name of the clusterobject, which replicated the object
+	ClusterObjectLabel = "cluster.jnnkrdb.de/clusterobject"
+
+	// GenerationAnnotation contains the generation of the clusterobject, which produced the object
+	GenerationAnnotation = "cluster.jnnkrdb.de/generation"
+
+	// ContentHashAnnotation contains the hash of the content of the replicated object
+	ContentHashAnnotation = "cluster.jnnkrdb.de/content-hash"
+)
+
+// merge the common labels and annotations of the clusterobject into the object. the
+// labels and annotations of the object take precedence.
+func setCommonMetadata(
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured) {
+
+	if len(co.Replicator.CommonLabels) > 0 {
+		var labels = maps.Clone(co.Replicator.CommonLabels)
+		maps.Copy(labels, typedObject.GetLabels())
+		typedObject.SetLabels(labels)
+	}
+
+	if len(co.Replicator.CommonAnnotations) > 0 {
+		var annotations = maps.Clone(co.Replicator.CommonAnnotations)
+		maps.Copy(annotations, typedObject.GetAnnotations())
+		typedObject.SetAnnotations(annotations)
+	}
+}
+
+// stamp the tracking labels and annotations on the object. the content hash is
+// calculated over the object without the tracking metadata.
+func setTrackingMetadata(
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured) error {
+
+	content, err := typedObject.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var hash = sha256.Sum256(content)
+
+	var labels = typedObject.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ManagedByLabel] = FieldManager
+	labels[ClusterObjectLabel] = co.GetName()
+	typedObject.SetLabels(labels)
+
+	var annotations = typedObject.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[GenerationAnnotation] = strconv.FormatInt(co.GetGeneration(), 10)
+	annotations[ContentHashAnnotation] = hex.EncodeToString(hash[:])
+	typedObject.SetAnnotations(annotations)
+
+	return nil
+}
+
+// remove the tracking labels and annotations from the object
+func removeTrackingMetadata(typedObject *unstructured.Unstructured) {
+
+	var labels = typedObject.GetLabels()
+	delete(labels, ManagedByLabel)
+	delete(labels, ClusterObjectLabel)
+	typedObject.SetLabels(labels)
+
+	var annotations = typedObject.GetAnnotations()
+	delete(annotations, GenerationAnnotation)
+	delete(annotations, ContentHashAnnotation)
+	typedObject.SetAnnotations(annotations)
+}
+
+// the key of a replicated object in a namespace
+type childKey struct {
+	namespace string
+	inventoryKey
+}
+
+// list the replicated objects of the clusterobject, which are either controlled by the clusterobject
+// or carry its tracking label. the labels can be removed, so the controller reference is checked as
+// well. the objects are listed once per kind in all namespaces, instead of fetching every object from
+// every namespace. only the metadata is read, so the cache does not have to keep the full objects.
+// kinds, which are not served by the api server, have no objects.
+func (r *ClusterObjectReconciler) listChildren(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	objects []*unstructured.Unstructured) (map[childKey]*unstructured.Unstructured, error) {
+
+	var children = map[childKey]*unstructured.Unstructured{}
+
+	var listed = map[string]struct{}{}
+	for _, object := range objects {
+		var gvk = object.GroupVersionKind()
+		if _, ok := listed[gvk.String()]; ok {
+			continue
+		}
+		listed[gvk.String()] = struct{}{}
+
+		var list = &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := r.List(ctx, list); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for i := range list.Items {
+			var item = &list.Items[i]
+			if item.GetLabels()[ClusterObjectLabel] != co.GetName() && !metav1.IsControlledBy(item, co) {
+				continue
+			}
+
+			var child = &unstructured.Unstructured{}
+			child.SetGroupVersionKind(gvk)
+			child.SetNamespace(item.GetNamespace())
+			child.SetName(item.GetName())
+			child.SetUID(item.GetUID())
+			child.SetResourceVersion(item.GetResourceVersion())
+			child.SetLabels(item.GetLabels())
+			child.SetAnnotations(item.GetAnnotations())
+			child.SetOwnerReferences(item.GetOwnerReferences())
+
+			children[childKey{
+				namespace:    item.GetNamespace(),
+				inventoryKey: newInventoryKey(object.GetAPIVersion(), gvk.Kind, item.GetName()),
+			}] = child
+		}
+	}
+
+	return children, nil
+}
+
+// find the replicated object of a resource in a namespace
+func findChild(
+	children map[childKey]*unstructured.Unstructured,
+	namespace string,
+	resource *unstructured.Unstructured) (*unstructured.Unstructured, bool) {
+
+	child, ok := children[childKey{
+		namespace:    namespace,
+		inventoryKey: newInventoryKey(resource.GetAPIVersion(), resource.GetKind(), resource.GetName()),
+	}]
+
+	return child, ok
+}
@@ -27,12 +27,18 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+	"github.com/jnnkrdb/r8r/internal/expression"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -55,6 +61,41 @@ func (r *ClusterObjectReconciler) throwOnError(
 		return err
 	}
 
+	// log the message and throw the event to the object
+	_ = r.reportError(ctx, co, err, event, msg)
+
+	// set the condition if any
+	if e := r.setCondition(ctx,
+		co,
+		Condition_Ready,
+		metav1.ConditionFalse,
+		fmt.Sprintf("Failed%s", event),
+		"%s: %v", msg, err,
+	); e != nil {
+		return e
+	}
+	return err
+}
+
+// This function is used to handle the errors of a single namespace. It logs the error
+// and throws the event, but leaves the conditions untouched, since the conditions
+// are calculated over all namespaces.
+//
+// parameters:
+//   - ctx context.Contex -> this is the default given context
+//   - err error          -> this is the thrown error, which should be handled
+func (r *ClusterObjectReconciler) reportError(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	err error,
+	event,
+	msg string) error {
+
+	// if the error is in fact nil, then leave early
+	if err == nil {
+		return err
+	}
+
 	var _log = log.FromContext(ctx)
 
 	// log the message with the error in the binary logs
@@ -67,17 +108,155 @@ func (r *ClusterObjectReconciler) throwOnError(
 		"%s: %v", msg, err,
 	)
 
-	// set the condition if any
-	if e := r.setCondition(ctx,
-		co,
-		Condition_Ready,
-		metav1.ConditionFalse,
-		fmt.Sprintf("Failed%s", event),
-		"%s: %v", msg, err,
-	); e != nil {
-		return e
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// FieldManager is the name of the field manager, which is used for the
+// server-side apply requests of the replicated objects
+const FieldManager = "r8r"
+
+// apply the given object with server-side apply, so r8r only owns the fields,
+// which are declared in the object. fields set by other controllers or admission
+// webhooks are kept. conflicts with other field managers are returned as errors,
+// unless the ownership is forced with the given options.
+func (r *ClusterObjectReconciler) applyObject(
+	ctx context.Context,
+	typedObject *unstructured.Unstructured,
+	opts ...client.ApplyOption) error {
+
+	// server managed metadata must not be part of an apply request
+	typedObject.SetResourceVersion("")
+	typedObject.SetManagedFields(nil)
+
+	return r.Apply(ctx,
+		client.ApplyConfigurationFromUnstructured(typedObject),
+		append([]client.ApplyOption{client.FieldOwner(FieldManager)}, opts...)...)
+}
+
+// legacyFieldManager is the field manager of the objects, which were written by older versions
+// of r8r with create and update. without a field manager, the api server derived the field
+// manager from the user agent of the client, which starts with the name of the binary.
+const legacyFieldManager = "r8r"
+
+// move the fields of the legacy field manager to the field manager of the apply requests.
+// otherwise the legacy field manager keeps the ownership of the fields, so fields, which are
+// removed from the clusterobject, are never removed from the object. the fields are only
+// moved once, objects, which were already applied, are not changed.
+func (r *ClusterObjectReconciler) upgradeManagedFields(
+	ctx context.Context,
+	typedObject *unstructured.Unstructured) error {
+
+	for _, entry := range typedObject.GetManagedFields() {
+		if entry.Manager == FieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return nil
+		}
+	}
+
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(typedObject, sets.New(legacyFieldManager), FieldManager)
+	if err != nil || patch == nil {
+		return err
+	}
+
+	log.FromContext(ctx).V(3).Info("upgrading the managed fields of the legacy field manager", "fieldManager", legacyFieldManager)
+
+	return r.Patch(ctx, typedObject, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// handle an existing object, which is not controlled by the clusterobject, according to the
+// conflict policy of the replicator. returns the options for the following apply request
+// and wether the namespace is skipped.
+func (r *ClusterObjectReconciler) resolveConflict(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	typedObject *unstructured.Unstructured) ([]client.ApplyOption, bool, error) {
+
+	var _log = log.FromContext(ctx).WithValues("conflictPolicy", co.Replicator.ConflictPolicy)
+
+	var conflictErr = fmt.Errorf("object [%s:%s/%s] already exists and is not controlled by the clusterobject",
+		typedObject.GetKind(),
+		typedObject.GetNamespace(),
+		typedObject.GetName())
+
+	switch co.Replicator.ConflictPolicy {
+
+	case clusterv1alpha1.ConflictPolicyAdopt:
+		// objects with another controller can not be adopted
+		if owner := metav1.GetControllerOf(typedObject); owner != nil {
+			return nil, false, r.reportError(ctx, co,
+				fmt.Errorf("%w, it is controlled by [%s:%s]", conflictErr, owner.Kind, owner.Name),
+				"ObjectAdoption",
+				"unable to adopt object")
+		}
+
+		_log.V(3).Info("adopting object")
+		if !co.Replicator.DryRun {
+			r.Recorder.Eventf(co, "Normal", "AdoptedObject",
+				"adopted object [%s:%s/%s]", typedObject.GetKind(), typedObject.GetNamespace(), typedObject.GetName())
+		}
+
+		// the declared fields are taken over from the managers, which created the object
+		return []client.ApplyOption{client.ForceOwnership}, false, nil
+
+	case clusterv1alpha1.ConflictPolicyOverwrite:
+		// release the controller flag of the current controller, since an object
+		// can only have one controller. a dry-run update is not persisted, so in
+		// dry-run mode the released reference is part of the applied object instead.
+		if metav1.GetControllerOf(typedObject) != nil && !co.Replicator.DryRun {
+			var references = typedObject.GetOwnerReferences()
+			for i := range references {
+				references[i].Controller = nil
+			}
+			typedObject.SetOwnerReferences(references)
+
+			if err := r.Update(ctx, typedObject, &client.UpdateOptions{FieldManager: FieldManager}); err != nil {
+				return nil, false, r.reportError(ctx, co, err, "ObjectOverwrite", "unable to release the current controller of the object")
+			}
+		}
+
+		_log.V(3).Info("overwriting object")
+		if !co.Replicator.DryRun {
+			r.Recorder.Eventf(co, "Normal", "OverwroteObject",
+				"took over object [%s:%s/%s]", typedObject.GetKind(), typedObject.GetNamespace(), typedObject.GetName())
+		}
+
+		return []client.ApplyOption{client.ForceOwnership}, false, nil
+
+	case clusterv1alpha1.ConflictPolicyFail:
+		return nil, false, r.reportError(ctx, co, conflictErr, "ObjectConflict", "conflicting object in namespace")
+
+	default:
+		_log.Info("skipping namespace, object is not controlled by the clusterobject")
+		r.Recorder.Eventf(co, "Warning", "SkippedConflict", "skipped namespace: %v", conflictErr)
+
+		return nil, true, nil
 	}
-	return err
+}
+
+// calculate the owner references of an object, whose controller is released. the references
+// are part of the applied object, so the release and the apply happen in a single request.
+func releasedReferences(typedObject *unstructured.Unstructured) []metav1.OwnerReference {
+
+	var references []metav1.OwnerReference
+	for _, reference := range typedObject.GetOwnerReferences() {
+		if reference.Controller != nil && *reference.Controller {
+			reference.Controller = ptr.To(false)
+			references = append(references, reference)
+		}
+	}
+
+	return references
+}
+
+// summarize a list of names for messages, to keep the messages short
+func summarizeNames(names []string) string {
+
+	const maxNames = 10
+
+	if len(names) <= maxNames {
+		return strings.Join(names, ", ")
+	}
+
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:maxNames], ", "), len(names)-maxNames)
 }
 
 // validate wether an object is existing in a given namespace or not
@@ -101,20 +280,146 @@ func (r *ClusterObjectReconciler) objectExists(
 	return true, nil
 }
 
-// validate wether an object is existing in a given namespace or not
+// validate wether an object should exist in a given namespace or not
 func (r *ClusterObjectReconciler) objectShouldExist(
-	namespace corev1.Namespace,
-	requiredNamespaces *corev1.NamespaceList) bool {
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) (bool, error) {
+
+	shouldExist, err := r.namespaceSelected(ctx, co, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	// the required objects are only looked up for the selected namespaces,
+	// to keep the number of requests small
+	if shouldExist && len(co.Replicator.RequireObjects) > 0 {
+		if shouldExist, err = r.requiredObjectsExist(ctx, co, namespace); err != nil {
+			return false, err
+		}
+	}
+
+	log.FromContext(ctx).V(5).Info("evaluated namespace",
+		"namespace.GetName()", namespace.GetName(),
+		"shouldExist", shouldExist)
+
+	return shouldExist, nil
+}
+
+// validate wether a namespace is selected by the replicator of the clusterobject. only the
+// selections and the exclusions are evaluated, the required objects are not looked up.
+func (r *ClusterObjectReconciler) namespaceSelected(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) (bool, error) {
 
-	for _, checkingNamespace := range requiredNamespaces.Items {
+	selected, err := r.matchesSelection(ctx, co, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	return selected && !r.isSystemNamespace(co, namespace), nil
+}
+
+// validate wether a namespace is a system namespace for the clusterobject. the system namespaces
+// are only targets, if they are selected by their names.
+func (r *ClusterObjectReconciler) isSystemNamespace(
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) bool {
+
+	return slices.Contains(r.SystemNamespaces, namespace.GetName()) &&
+		(co.Replicator.Namespaces == nil || !slices.Contains(co.Replicator.Namespaces.Names, namespace.GetName()))
+}
+
+// validate wether a namespace matches the selections of the replicator and is not excluded.
+// the system namespaces are not considered.
+func (r *ClusterObjectReconciler) matchesSelection(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	namespace corev1.Namespace) (bool, error) {
+
+	// the selections are combined, only the configured selections are evaluated. without
+	// any selection, no namespace is selected, with targetAll every namespace is selected.
+	var configured, shouldExist = co.Replicator.TargetAll, true
+
+	// the names of the namespaces are combined with the labelselector according to
+	// the operator, if there is no labelselector, only the names are evaluated
+	if co.Replicator.LabelSelector != nil || co.Replicator.Namespaces != nil {
+		matches, err := matchesLabelsAndNames(co, namespace)
+		if err != nil {
+			return false, err
+		}
+		configured, shouldExist = true, matches
+	}
 
-		if checkingNamespace.GetName() == namespace.GetName() {
+	// the expression must match as well
+	if namespaceCEL := co.Replicator.NamespaceCEL; namespaceCEL != "" {
+		matchesCEL, err := expression.MatchNamespace(namespaceCEL, &namespace)
+		if err != nil {
+			return false, err
+		}
+		configured, shouldExist = true, shouldExist && matchesCEL
+	}
 
-			return true
+	// the namespace must be part of the tree below the root namespace
+	if hierarchy := co.Replicator.Hierarchy; hierarchy != nil {
+		configured = true
+		if shouldExist {
+			_, inTree, err := r.hierarchyDepth(ctx, hierarchy, namespace)
+			if err != nil {
+				return false, err
+			}
+			shouldExist = inTree
 		}
 	}
 
-	return false
+	if !configured {
+		shouldExist = false
+	}
+
+	// the exclusions are applied after the selection, an excluded namespace is no
+	// target, so existing objects in it are deleted
+	excluded, err := namespaceExcluded(co, namespace)
+	if err != nil {
+		return false, err
+	}
+	if excluded {
+		shouldExist = false
+	}
+
+	return shouldExist, nil
+}
+
+// calculate the list of namespaces, which are required to inherit the defined object
+func (r *ClusterObjectReconciler) requiredNamespaces(
+	ctx context.Context,
+	co *clusterv1alpha1.ClusterObject,
+	namespaces *corev1.NamespaceList) (*corev1.NamespaceList, error) {
+
+	var requiredNamespaces = &corev1.NamespaceList{}
+	for _, namespace := range namespaces.Items {
+
+		shouldExist, err := r.objectShouldExist(ctx, co, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if shouldExist {
+			requiredNamespaces.Items = append(requiredNamespaces.Items, namespace)
+		}
+	}
+
+	return requiredNamespaces, nil
+}
+
+// collect the names of the given namespaces, to look them up without parsing the list
+func namespaceNames(namespaces *corev1.NamespaceList) map[string]struct{} {
+
+	var names = make(map[string]struct{}, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		names[namespace.GetName()] = struct{}{}
+	}
+
+	return names
 }
 
 // ----------------------------------------------------------------------------------------------------------------------- conditions
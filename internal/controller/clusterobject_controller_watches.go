@@ -0,0 +1,343 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// dynamicWatches registers watches for the kinds of the replicated objects at runtime.
+// the kinds are not known at startup, so every new kind is added lazily, as soon as
+// a clusterobject with this kind gets reconciled.
+type dynamicWatches struct {
+	controller controller.Controller
+	cache      cache.Cache
+
+	mu      sync.Mutex
+	watched map[string]struct{}
+}
+
+// the purposes of the dynamic watches. a kind may be watched for several purposes, e.g. as
+// replicated kind and as kind of a source, each purpose maps the events with its own handler.
+const (
+	childWatch    = "child"
+	sourceWatch   = "source"
+	requiredWatch = "required"
+)
+
+// create the key of a dynamic watch from its purpose and the watched kind
+func watchKey(purpose string, gvk schema.GroupVersionKind) string {
+	return purpose + "/" + gvk.String()
+}
+
+// ensureWatch makes sure, that the given object is watched with the given handler. the key
+// identifies the watch, every key is only watched once. the kinds are not known at startup,
+// so the watches are added lazily, as soon as a clusterobject requires them.
+func (r *ClusterObjectReconciler) ensureWatch(
+	ctx context.Context,
+	key string,
+	object client.Object,
+	eventHandler handler.EventHandler,
+	predicates ...predicate.Predicate) error {
+
+	// the watches are only available, if the reconciler was set up with a manager
+	if r.watches == nil || r.watches.controller == nil {
+		return nil
+	}
+
+	r.watches.mu.Lock()
+	defer r.watches.mu.Unlock()
+
+	if _, ok := r.watches.watched[key]; ok {
+		return nil
+	}
+
+	if err := r.watches.controller.Watch(
+		source.Kind(r.watches.cache, object, eventHandler, predicates...)); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("watching kind", "key", key)
+
+	r.watches.watched[key] = struct{}{}
+
+	return nil
+}
+
+// ensureChildWatch makes sure, that the kind of the given object is watched. events of
+// objects of this kind are mapped back to the clusterobject through the tracking label,
+// so drift or deletion of a replicated object triggers the reconciliation immediately.
+func (r *ClusterObjectReconciler) ensureChildWatch(
+	ctx context.Context,
+	typedObject *unstructured.Unstructured) error {
+
+	var watchedObject = &unstructured.Unstructured{}
+	watchedObject.SetGroupVersionKind(typedObject.GroupVersionKind())
+
+	return r.ensureWatch(ctx,
+		watchKey(childWatch, typedObject.GroupVersionKind()),
+		watchedObject,
+		handler.EnqueueRequestsFromMapFunc(mapChildToClusterObject))
+}
+
+// map a replicated object to its clusterobject. the clusterobject is found through the
+// tracking label, objects without the label are mapped through their controller reference.
+func mapChildToClusterObject(
+	_ context.Context,
+	obj client.Object) []reconcile.Request {
+
+	var name = obj.GetLabels()[ClusterObjectLabel]
+
+	if owner := metav1.GetControllerOf(obj); name == "" && owner != nil &&
+		owner.Kind == "ClusterObject" &&
+		owner.APIVersion == clusterv1alpha1.GroupVersion.String() {
+		name = owner.Name
+	}
+
+	if name == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{
+			Name: name,
+		},
+	}}
+}
+
+// ensureSourceWatch makes sure, that the kind of a source object is watched. events of
+// source objects are mapped to the clusterobjects, which reference the source, with the
+// help of the field index.
+func (r *ClusterObjectReconciler) ensureSourceWatch(
+	ctx context.Context,
+	gvk schema.GroupVersionKind) error {
+
+	var watchedObject = &unstructured.Unstructured{}
+	watchedObject.SetGroupVersionKind(gvk)
+
+	return r.ensureWatch(ctx,
+		watchKey(sourceWatch, gvk),
+		watchedObject,
+		handler.EnqueueRequestsFromMapFunc(r.mapSourceToClusterObjects))
+}
+
+// map a source object to the clusterobjects, which reference the source
+func (r *ClusterObjectReconciler) mapSourceToClusterObjects(
+	ctx context.Context,
+	obj client.Object) []reconcile.Request {
+
+	var _log = log.FromContext(ctx)
+
+	var gvk = obj.GetObjectKind().GroupVersionKind()
+
+	var list = &clusterv1alpha1.ClusterObjectList{}
+	if err := r.List(ctx, list, client.MatchingFields{
+		sourceIndexField: sourceKey(gvk.GroupVersion().String(), gvk.Kind, obj.GetNamespace(), obj.GetName()),
+	}); err != nil {
+		_log.Error(err, "error receiving list of clusterobjects, cannot invoke reconciliation")
+		return nil
+	}
+
+	var requests = make([]reconcile.Request, 0, len(list.Items))
+	for _, clusterObject := range list.Items {
+		_log.V(3).Info("enqueue clusterobject for source",
+			"clusterObject", clusterObject.GetName(),
+			"source", obj.GetName())
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: clusterObject.GetName(),
+			},
+		})
+	}
+
+	return requests
+}
+
+// ensureRequiredWatch makes sure, that the kind of required objects is watched. only the
+// metadata of the objects is watched and only their creation, deletion and label changes
+// are relevant, since only these can change the targets of a clusterobject.
+func (r *ClusterObjectReconciler) ensureRequiredWatch(
+	ctx context.Context,
+	gvk schema.GroupVersionKind) error {
+
+	var watchedObject = &metav1.PartialObjectMetadata{}
+	watchedObject.SetGroupVersionKind(gvk)
+
+	return r.ensureWatch(ctx,
+		watchKey(requiredWatch, gvk),
+		watchedObject,
+		handler.EnqueueRequestsFromMapFunc(r.mapRequiredToClusterObjects),
+		predicate.LabelChangedPredicate{})
+}
+
+// map a required object to the clusterobjects, which require objects of its kind. the
+// label selectors are not evaluated, since the previous labels of the object are unknown.
+func (r *ClusterObjectReconciler) mapRequiredToClusterObjects(
+	ctx context.Context,
+	obj client.Object) []reconcile.Request {
+
+	var _log = log.FromContext(ctx)
+
+	var gvk = obj.GetObjectKind().GroupVersionKind()
+
+	var list = &clusterv1alpha1.ClusterObjectList{}
+	if err := r.List(ctx, list, client.MatchingFields{
+		requiredIndexField: requiredKey(gvk.GroupVersion().String(), gvk.Kind),
+	}); err != nil {
+		_log.Error(err, "error receiving list of clusterobjects, cannot invoke reconciliation")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, clusterObject := range list.Items {
+		if !slices.ContainsFunc(clusterObject.Replicator.RequireObjects, func(required clusterv1alpha1.ClusterObjectRequiredObject) bool {
+			return required.APIVersion == gvk.GroupVersion().String() &&
+				required.Kind == gvk.Kind &&
+				(required.Name == "" || required.Name == obj.GetName())
+		}) {
+			continue
+		}
+
+		_log.V(3).Info("enqueue clusterobject for required object",
+			"clusterObject", clusterObject.GetName(),
+			"namespace", obj.GetNamespace(),
+			"name", obj.GetName())
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: clusterObject.GetName(),
+			},
+		})
+	}
+
+	return requests
+}
+
+// namespaceEventHandler maps the events of namespaces to the clusterobjects, which are affected
+// by the namespace. a clusterobject is affected, if it selected the namespace before or after
+// the change. the selectors are evaluated against the cached clusterobjects, so only the
+// affected clusterobjects are enqueued.
+func (r *ClusterObjectReconciler) namespaceEventHandler() handler.EventHandler {
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueForNamespaces(ctx, q, false, e.Object)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			// if only the annotations changed, only the clusterobjects, which read the
+			// annotations, are affected. changes of the ignore annotation affect the
+			// selection of all clusterobjects.
+			var onlyAnnotations = maps.Equal(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) &&
+				e.ObjectOld.GetAnnotations()[IgnoreNamespaceAnnotation] == e.ObjectNew.GetAnnotations()[IgnoreNamespaceAnnotation]
+			r.enqueueForNamespaces(ctx, q, onlyAnnotations, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueForNamespaces(ctx, q, false, e.Object)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueForNamespaces(ctx, q, false, e.Object)
+		},
+	}
+}
+
+// enqueue all clusterobjects, which select at least one of the given versions of a namespace.
+// if onlyAnnotations is set, only the clusterobjects, which read the annotations of the
+// namespaces, are enqueued.
+func (r *ClusterObjectReconciler) enqueueForNamespaces(
+	ctx context.Context,
+	q workqueue.TypedRateLimitingInterface[reconcile.Request],
+	onlyAnnotations bool,
+	objects ...client.Object) {
+
+	var _log = log.FromContext(ctx)
+
+	var list = &clusterv1alpha1.ClusterObjectList{}
+	if err := r.List(ctx, list, &client.ListOptions{}); err != nil {
+		_log.Error(err, "error receiving list of clusterobjects, cannot invoke reconciliation")
+		return
+	}
+
+	for i := range list.Items {
+		var clusterObject = &list.Items[i]
+
+		if onlyAnnotations && !readsNamespaceAnnotations(clusterObject) {
+			continue
+		}
+
+		for _, obj := range objects {
+			namespace, ok := obj.(*corev1.Namespace)
+			if !ok {
+				continue
+			}
+
+			// if the selection can not be evaluated, then the clusterobject is
+			// enqueued anyway, the reconciliation reports the error. the required
+			// objects are not looked up here, the reconciliation evaluates them.
+			selected, err := r.namespaceSelected(ctx, clusterObject, *namespace)
+			if err != nil || selected {
+				_log.V(3).Info("enqueue clusterobject for namespace",
+					"clusterObject", clusterObject.GetName(),
+					"namespace", namespace.GetName())
+
+				q.Add(reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Name: clusterObject.GetName(),
+					},
+				})
+				break
+			}
+		}
+	}
+}
+
+// validate wether the clusterobject reads the annotations of the namespaces, either in
+// its templates, in the expression, which selects the namespaces, or in the hierarchy
+func readsNamespaceAnnotations(co *clusterv1alpha1.ClusterObject) bool {
+	return co.Replicator.Template ||
+		co.Replicator.NamespaceCEL != "" ||
+		(co.Replicator.Hierarchy != nil && co.Replicator.Hierarchy.ParentAnnotation != "")
+}
@@ -0,0 +1,128 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// a controller, which only counts the registered watches
+type countingController struct {
+	controller.Controller
+	watches int
+}
+
+func (c *countingController) Watch(source.TypedSource[reconcile.Request]) error {
+	c.watches++
+	return nil
+}
+
+func TestEnsureWatch(t *testing.T) {
+
+	var ctx = context.Background()
+	var c = &countingController{}
+	var r = &ClusterObjectReconciler{watches: &dynamicWatches{
+		controller: c,
+		watched:    map[string]struct{}{},
+	}}
+
+	var configMap = newResource("v1", "ConfigMap", "a")
+	var secret = newResource("v1", "Secret", "b")
+	var configMapKind = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	for _, ensure := range []func() error{
+		func() error { return r.ensureChildWatch(ctx, configMap) },
+		func() error { return r.ensureChildWatch(ctx, newResource("v1", "ConfigMap", "other")) },
+		func() error { return r.ensureChildWatch(ctx, secret) },
+		func() error { return r.ensureSourceWatch(ctx, configMapKind) },
+		func() error { return r.ensureSourceWatch(ctx, configMap.GroupVersionKind()) },
+		func() error { return r.ensureRequiredWatch(ctx, configMap.GroupVersionKind()) },
+	} {
+		if err := ensure(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// every kind is watched once per purpose
+	if c.watches != 4 || len(r.watches.watched) != 4 {
+		t.Errorf("expected 4 watches, got %d watches with %d keys", c.watches, len(r.watches.watched))
+	}
+}
+
+func TestEnsureWatchWithoutManager(t *testing.T) {
+
+	var r = &ClusterObjectReconciler{}
+	if err := r.ensureWatch(context.Background(), "child/v1, Kind=ConfigMap", &unstructured.Unstructured{}, nil); err != nil {
+		t.Errorf("expected no error without a manager, got %v", err)
+	}
+}
+
+func TestEnqueueForNamespacesSkipsRequiredObjects(t *testing.T) {
+
+	var ctx = context.Background()
+	var co = newConfigMapClusterObject("required", map[string]any{"key": "value"})
+	co.Replicator.RequireObjects = []clusterv1alpha1.ClusterObjectRequiredObject{
+		{APIVersion: "v1", Kind: "Secret", Name: "missing"},
+	}
+
+	r, c := newFakeReconciler(t, co)
+
+	// the mapper must not look up the required objects, only the clusterobjects are listed
+	var lists int
+	r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			if _, ok := list.(*clusterv1alpha1.ClusterObjectList); !ok {
+				lists++
+			}
+			return c.List(ctx, list, opts...)
+		},
+	})
+
+	var q = workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer q.ShutDown()
+
+	r.enqueueForNamespaces(ctx, q, false, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+
+	if lists != 0 {
+		t.Errorf("expected no lookup of the required objects, got %d lists", lists)
+	}
+	if q.Len() != 1 {
+		t.Errorf("expected the clusterobject to be enqueued, got %d requests", q.Len())
+	}
+}
@@ -0,0 +1,124 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+// create a list of namespaces with the names ns-0 to ns-<count-1>
+func newNamespaceList(count int) *corev1.NamespaceList {
+	var namespaces = &corev1.NamespaceList{}
+	for i := range count {
+		namespaces.Items = append(namespaces.Items, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%d", i)}})
+	}
+	return namespaces
+}
+
+func TestProcessNamespaces(t *testing.T) {
+
+	var r = &ClusterObjectReconciler{MaxConcurrentNamespaces: 3}
+	var namespaces = newNamespaceList(20)
+
+	var running, peak atomic.Int32
+	targets, errs := r.processNamespaces(context.Background(), &clusterv1alpha1.ClusterObject{}, namespaces,
+		func(ctx context.Context, namespace corev1.Namespace) ([]clusterv1alpha1.ClusterObjectTarget, error) {
+
+			var current = running.Add(1)
+			defer running.Add(-1)
+			for {
+				var previous = peak.Load()
+				if current <= previous || peak.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+
+			if namespace.GetName() == "ns-4" {
+				return nil, errors.New("failed")
+			}
+			return []clusterv1alpha1.ClusterObjectTarget{{Namespace: namespace.GetName()}}, nil
+		})
+
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent namespaces, got %d", peak.Load())
+	}
+
+	// a failing namespace does not stop the other namespaces
+	if len(errs) != 1 || errs[0].Error() != "namespace ns-4: failed" {
+		t.Errorf("expected the error of ns-4, got %v", errs)
+	}
+	if len(targets) != 19 {
+		t.Fatalf("expected 19 targets, got %d", len(targets))
+	}
+
+	// the targets are collected in the order of the namespaces
+	for i, target := range targets {
+		var expected = i
+		if i >= 4 {
+			expected++
+		}
+		if target.Namespace != fmt.Sprintf("ns-%d", expected) {
+			t.Fatalf("expected target %d in ns-%d, got %s", i, expected, target.Namespace)
+		}
+	}
+}
+
+func TestProcessNamespacesStopsOnCancel(t *testing.T) {
+
+	var r = &ClusterObjectReconciler{MaxConcurrentNamespaces: 2}
+	var namespaces = newNamespaceList(50)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var processed atomic.Int32
+	var once sync.Once
+	_, errs := r.processNamespaces(ctx, &clusterv1alpha1.ClusterObject{}, namespaces,
+		func(ctx context.Context, namespace corev1.Namespace) ([]clusterv1alpha1.ClusterObjectTarget, error) {
+			processed.Add(1)
+			once.Do(cancel)
+			return nil, nil
+		})
+
+	// the workers, which already started, finish their namespace
+	if processed.Load() > 2 {
+		t.Errorf("expected at most 2 processed namespaces after the cancellation, got %d", processed.Load())
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("expected a single cancellation error, got %v", errs)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package expression contains the CEL expressions, which are used by the clusterobjects
+// to select their target namespaces. The expressions are compiled by the admission webhook
+// and by the controller, so both report the same errors.
+package expression
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// NamespaceVariable is the name of the variable, which contains the namespace. namespace
+	// is a reserved word in CEL, object is used like in the admission policies of kubernetes.
+	NamespaceVariable = "object"
+
+	// the maximum cost of an evaluation, to protect the controller from expressions,
+	// which run too long
+	costLimit = 1000000
+
+	// the maximum number of compiled programs, which are cached
+	maxCachedPrograms = 256
+)
+
+var (
+	// the environment of the namespace expressions
+	environment = sync.OnceValues(func() (*cel.Env, error) {
+		return cel.NewEnv(
+			cel.Variable(NamespaceVariable, cel.MapType(cel.StringType, cel.DynType)),
+			cel.OptionalTypes(),
+			ext.Strings(),
+			ext.Sets(),
+		)
+	})
+
+	// the compiled programs, by their expressions
+	programs   = map[string]cel.Program{}
+	programsMu sync.Mutex
+)
+
+// CompileNamespaceExpression compiles and type-checks an expression, which selects
+// namespaces. The expression must evaluate to a bool.
+func CompileNamespaceExpression(expression string) (cel.Program, error) {
+
+	programsMu.Lock()
+	defer programsMu.Unlock()
+
+	if program, ok := programs[expression]; ok {
+		return program, nil
+	}
+
+	env, err := environment()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid namespace expression: %w", issues.Err())
+	}
+
+	// the fields of the namespace are dynamic, so the result is only known at runtime
+	if outputType := ast.OutputType(); !outputType.IsExactType(cel.BoolType) && !outputType.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("invalid namespace expression: must evaluate to bool, not %s", outputType)
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace expression: %w", err)
+	}
+
+	if len(programs) >= maxCachedPrograms {
+		programs = map[string]cel.Program{}
+	}
+	programs[expression] = program
+
+	return program, nil
+}
+
+// MatchNamespace evaluates an expression against a namespace
+func MatchNamespace(expression string, namespace *corev1.Namespace) (bool, error) {
+
+	program, err := CompileNamespaceExpression(expression)
+	if err != nil {
+		return false, err
+	}
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(namespace)
+	if err != nil {
+		return false, err
+	}
+
+	// labels and annotations are always available, so the expressions do not
+	// have to check their existence
+	metadata, _ := object["metadata"].(map[string]interface{})
+	for _, field := range []string{"labels", "annotations"} {
+		if _, ok := metadata[field]; !ok {
+			metadata[field] = map[string]interface{}{}
+		}
+	}
+
+	result, _, err := program.Eval(map[string]interface{}{NamespaceVariable: object})
+	if err != nil {
+		return false, fmt.Errorf("error evaluating the namespace expression: %w", err)
+	}
+
+	matches, ok := result.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("error evaluating the namespace expression: must evaluate to bool, not %s", result.Type())
+	}
+
+	return matches, nil
+}
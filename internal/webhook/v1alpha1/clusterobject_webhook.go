@@ -0,0 +1,133 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+	"github.com/jnnkrdb/r8r/internal/expression"
+)
+
+// log is for logging in this package.
+var clusterobjectlog = logf.Log.WithName("clusterobject-resource")
+
+// SetupClusterObjectWebhookWithManager registers the webhook for ClusterObject in the manager.
+func SetupClusterObjectWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&clusterv1alpha1.ClusterObject{}).
+		WithValidator(&ClusterObjectCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-cluster-jnnkrdb-de-v1alpha1-clusterobject,mutating=false,failurePolicy=fail,sideEffects=None,groups=cluster.jnnkrdb.de,resources=clusterobjects,verbs=create;update,versions=v1alpha1,name=vclusterobject-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ClusterObjectCustomValidator validates the ClusterObjects, when they are created or updated.
+// It covers the validations, which can not be expressed in the CRD, e.g. the compilation
+// of the CEL expressions.
+type ClusterObjectCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ClusterObjectCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type ClusterObject.
+func (v *ClusterObjectCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	clusterobject, ok := obj.(*clusterv1alpha1.ClusterObject)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterObject object but got %T", obj)
+	}
+	clusterobjectlog.V(3).Info("validation for ClusterObject upon creation", "name", clusterobject.GetName())
+
+	return warnClusterObject(clusterobject), validateClusterObject(clusterobject)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ClusterObject.
+func (v *ClusterObjectCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	clusterobject, ok := newObj.(*clusterv1alpha1.ClusterObject)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterObject object for the newObj but got %T", newObj)
+	}
+	clusterobjectlog.V(3).Info("validation for ClusterObject upon update", "name", clusterobject.GetName())
+
+	// the clusterobject is being deleted, it has to pass, so the finalizer can be removed
+	if !clusterobject.GetDeletionTimestamp().IsZero() {
+		return nil, nil
+	}
+
+	return warnClusterObject(clusterobject), validateClusterObject(clusterobject)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ClusterObject.
+func (v *ClusterObjectCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// warn about a clusterobject, which is valid but most likely not configured as intended
+func warnClusterObject(clusterobject *clusterv1alpha1.ClusterObject) admission.Warnings {
+
+	var warnings admission.Warnings
+
+	// without a selection, no namespace is selected. selecting all namespaces
+	// requires targetAll.
+	var replicator = clusterobject.Replicator
+	if !replicator.TargetAll && replicator.LabelSelector == nil && replicator.Namespaces == nil &&
+		replicator.NamespaceCEL == "" && replicator.Hierarchy == nil {
+		warnings = append(warnings, "replicator selects no namespace, set targetAll to select all namespaces "+
+			"or one of labelSelector, namespaces, namespaceCEL or hierarchy")
+	}
+
+	return warnings
+}
+
+// validate the fields of a clusterobject, which can not be validated by the CRD
+func validateClusterObject(clusterobject *clusterv1alpha1.ClusterObject) error {
+
+	var errs field.ErrorList
+	var replicator = field.NewPath("replicator")
+
+	// the expression is compiled and type-checked, so errors are reported before
+	// the clusterobject is stored
+	if namespaceCEL := clusterobject.Replicator.NamespaceCEL; namespaceCEL != "" {
+		if _, err := expression.CompileNamespaceExpression(namespaceCEL); err != nil {
+			errs = append(errs, field.Invalid(replicator.Child("namespaceCEL"), namespaceCEL, err.Error()))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		clusterv1alpha1.GroupVersion.WithKind("ClusterObject").GroupKind(),
+		clusterobject.GetName(),
+		errs)
+}
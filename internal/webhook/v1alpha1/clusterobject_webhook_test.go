@@ -0,0 +1,97 @@
+/*
+MIT License
+
+Copyright (c) 2017
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1alpha1 "github.com/jnnkrdb/r8r/api/v1alpha1"
+)
+
+var _ = Describe("ClusterObject Webhook", func() {
+	var (
+		obj       *clusterv1alpha1.ClusterObject
+		oldObj    *clusterv1alpha1.ClusterObject
+		validator ClusterObjectCustomValidator
+		ctx       = context.Background()
+	)
+
+	BeforeEach(func() {
+		obj = &clusterv1alpha1.ClusterObject{ObjectMeta: metav1.ObjectMeta{Name: "test-resource"}}
+		oldObj = obj.DeepCopy()
+		validator = ClusterObjectCustomValidator{}
+	})
+
+	Context("When validating the namespaceCEL expression", func() {
+		It("should admit a clusterobject without an expression", func() {
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("should admit a valid expression", func() {
+			obj.Replicator.NamespaceCEL = `object.metadata.annotations[?'team'].orValue('') in ['a', 'b']`
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("should deny an expression, which does not compile", func() {
+			obj.Replicator.NamespaceCEL = `object.metadata.labels[`
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("replicator.namespaceCEL"))
+		})
+
+		It("should deny an expression, which does not return a bool", func() {
+			obj.Replicator.NamespaceCEL = `size(object.metadata.labels)`
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("should admit an invalid expression, if the clusterobject is being deleted", func() {
+			obj.Replicator.NamespaceCEL = `object.metadata.labels[`
+			now := metav1.Now()
+			obj.DeletionTimestamp = &now
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When validating the namespace selection", func() {
+		It("should warn about a clusterobject without a selection", func() {
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0]).To(ContainSubstring("targetAll"))
+		})
+
+		It("should not warn about a clusterobject, which targets all namespaces", func() {
+			obj.Replicator.TargetAll = true
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeEmpty())
+		})
+	})
+})